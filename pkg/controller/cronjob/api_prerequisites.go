@@ -0,0 +1,46 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+// This file carries no code. It is the single place that tracks every batchv1 spec and
+// status field, and every batchv1 type, that this package reads or writes but that does
+// not exist on the real k8s.io/api/batch/v1 this package imports. That package is not
+// vendored into this checkout, so nothing below compiles against a real
+// k8s.io/api/batch/v1 yet: each entry needs its corresponding upstream API (and
+// generated deepcopy/conversion/openapi) change authored and vendored alongside it
+// before this controller can build for real. Do not add another field or type in this
+// family without adding it here first.
+//
+// batchv1.CronJobSpec:
+//   - ManagedBy (utils.go, isManagedByExternalController) — introduced to delegate
+//     scheduling decisions to an external controller.
+//   - TimePlan (timeplan.go, getSchedule) — introduced alongside the new
+//     batchv1.TimePlan type itself; there is no upstream equivalent to reuse.
+//   - MaxQueuedRuns (queue.go, maxQueuedRuns) — caps Status.PendingSchedules below.
+//   - CleanupPolicy (finalizer.go, syncCronJobDeletion) — introduced alongside the new
+//     batchv1.CronJobCleanupPolicy type and its Delete/Orphan/Wait values.
+//
+// batchv1.CronJobStatus:
+//   - PendingSchedules (queue.go) — the queue spec.concurrencyPolicy: Queue buffers
+//     missed/blocked schedule times into.
+//   - LastTriggerTime (trigger.go) — records the most recently honored manual trigger.
+//
+// Deliberately NOT on this list: spec.TimeZone (timezone.go, resolveTimeZoneAndSchedule)
+// reuses the real spec.timeZone field, which has been GA on batchv1.CronJobSpec since
+// Kubernetes 1.27 with the same name and the same IANA-zone-for-the-schedule meaning.
+// It is not a new field and needs no upstream change; only the CRON_TZ=/TZ= inline
+// schedule-prefix handling layered on top of it is new to this package.
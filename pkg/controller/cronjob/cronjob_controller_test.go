@@ -0,0 +1,129 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// topOfTheHour is a fixed point in time used by several test cases alongside
+// justBeforeTheHour/justAfterTheHour.
+func topOfTheHour() time.Time {
+	T1, err := time.Parse(time.RFC3339, "2016-05-19T10:00:00Z")
+	if err != nil {
+		panic("test setup error")
+	}
+	return T1
+}
+
+// fakeJobControl is a fake implementation of jobControlInterface for use in tests.
+type fakeJobControl struct {
+	sync.Mutex
+	Job           *batchv1.Job
+	Jobs          []batchv1.Job
+	DeleteJobName []string
+	Err           error
+	CreateErr     error
+}
+
+var _ jobControlInterface = &fakeJobControl{}
+
+func (f *fakeJobControl) GetJob(namespace, name string) (*batchv1.Job, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if f.Job == nil {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: "batch", Resource: "jobs"}, name)
+	}
+	return f.Job, nil
+}
+
+func (f *fakeJobControl) CreateJob(namespace string, job *batchv1.Job) (*batchv1.Job, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.CreateErr != nil {
+		return nil, f.CreateErr
+	}
+	job = job.DeepCopy()
+	job.SelfLink = fmt.Sprintf("/apis/batch/v1/namespaces/%s/jobs/%s", namespace, job.Name)
+	f.Jobs = append(f.Jobs, *job)
+	return job, nil
+}
+
+func (f *fakeJobControl) DeleteJob(namespace string, name string) error {
+	f.Lock()
+	defer f.Unlock()
+	f.DeleteJobName = append(f.DeleteJobName, name)
+	return nil
+}
+
+// fakeCJControl is a fake implementation of cronJobControlInterface for use in tests.
+type fakeCJControl struct {
+	CronJob *batchv1.CronJob
+	Updates []batchv1.CronJob
+}
+
+var _ cronJobControlInterface = &fakeCJControl{}
+
+func (c *fakeCJControl) UpdateStatus(cj *batchv1.CronJob) (*batchv1.CronJob, error) {
+	c.Updates = append(c.Updates, *cj)
+	c.CronJob = cj
+	return cj, nil
+}
+
+func (c *fakeCJControl) Update(cj *batchv1.CronJob) (*batchv1.CronJob, error) {
+	c.Updates = append(c.Updates, *cj)
+	c.CronJob = cj
+	return cj, nil
+}
+
+// fakeJobStateControl is an in-memory implementation of jobStateControlInterface for
+// use in tests, keyed by CronJob UID the way the real ConfigMap-per-CronJob control is
+// keyed by CronJob name.
+type fakeJobStateControl struct {
+	states map[types.UID]jobState
+}
+
+var _ jobStateControlInterface = &fakeJobStateControl{}
+
+func (f *fakeJobStateControl) Get(cj *batchv1.CronJob) (*jobState, error) {
+	if f.states == nil {
+		return nil, nil
+	}
+	s, ok := f.states[cj.UID]
+	if !ok {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+func (f *fakeJobStateControl) Save(cj *batchv1.CronJob, state jobState) error {
+	if f.states == nil {
+		f.states = map[types.UID]jobState{}
+	}
+	f.states[cj.UID] = state
+	return nil
+}
@@ -0,0 +1,732 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"k8s.io/klog/v2"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	batchv1informers "k8s.io/client-go/informers/batch/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	batchv1listers "k8s.io/client-go/listers/batch/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// Reasons for CronJob events
+	reasonSuccessfulDelete     = "SuccessfulDelete"
+	reasonFailedDelete         = "FailedDelete"
+	reasonSuccessfulCreate     = "SuccessfulCreate"
+	reasonFailedCreate         = "FailedCreate"
+	reasonUnexpectedJob        = "UnexpectedJob"
+	reasonMissSchedule         = "MissSchedule"
+	reasonInvalidSchedule      = "InvalidSchedule"
+	reasonInvalidTimeZone      = "InvalidTimeZone"
+	reasonDelegatedToManagedBy = "DelegatedToManagedBy"
+
+	// nextScheduleDelta is the small amount of time to add to the requeue delay, to account
+	// for clock drift between the controller and the api server.
+	nextScheduleDelta = 100 * time.Millisecond
+
+	// defaultManagedBy is the value of spec.managedBy that marks a CronJob as owned by
+	// this in-tree controller. Any other non-empty value delegates Job lifecycle
+	// decisions to an external controller.
+	defaultManagedBy = "kubernetes.io/cronjob-controller"
+)
+
+// ControllerV2 is a controller for CronJobs. Unlike ControllerV1, this controller
+// is implemented using the informer pattern and recomputes the next schedule time
+// itself rather than using the workqueue's rate-limiter for retries.
+type ControllerV2 struct {
+	queue          workqueue.RateLimitingInterface
+	recorder       record.EventRecorder
+	jobControl     jobControlInterface
+	cronJobControl cronJobControlInterface
+	// jobStateControl persists in-flight scheduling attempts so a restart can tell a
+	// crashed-mid-create attempt apart from one that never started. It is nil unless
+	// built via NewControllerV2, in which case scheduling falls back to relying solely
+	// on the deterministic Job name to dedupe (see getJobFromTemplate2).
+	jobStateControl     jobStateControlInterface
+	jobLister           batchv1listers.JobLister
+	cronJobLister       batchv1listers.CronJobLister
+	jobListerSynced     cache.InformerSynced
+	cronJobListerSynced cache.InformerSynced
+
+	// metrics publishes overdue-schedule and schedule-delay measurements. It is nil
+	// unless built via NewControllerV2.
+	metrics *cronJobMetrics
+
+	// now is a function that returns current time, done to facilitate unit tests
+	now func() time.Time
+}
+
+// NewControllerV2 creates and initializes a new ControllerV2 using DefaultMetricsConfig().
+func NewControllerV2(jobInformer batchv1informers.JobInformer, cronJobInformer batchv1informers.CronJobInformer, kubeClient clientset.Interface) (*ControllerV2, error) {
+	return NewControllerV2WithMetricsConfig(jobInformer, cronJobInformer, kubeClient, DefaultMetricsConfig())
+}
+
+// NewControllerV2WithMetricsConfig is like NewControllerV2 but allows overriding the
+// histogram bucket and reset-interval knobs in MetricsConfig. The metrics are shared by
+// every ControllerV2 in the process, so only the MetricsConfig of the first one built
+// takes effect (see newCronJobMetrics).
+func NewControllerV2WithMetricsConfig(jobInformer batchv1informers.JobInformer, cronJobInformer batchv1informers.CronJobInformer, kubeClient clientset.Interface, metricsCfg MetricsConfig) (*ControllerV2, error) {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartStructuredLogging(0)
+	eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+
+	jm := &ControllerV2{
+		queue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "cronjob"),
+		recorder: eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "cronjob-controller"}),
+
+		jobControl:      realJobControl{KubeClient: kubeClient},
+		cronJobControl:  &realCronJobControl{KubeClient: kubeClient},
+		jobStateControl: &configMapJobStateControl{KubeClient: kubeClient},
+
+		jobLister:     jobInformer.Lister(),
+		cronJobLister: cronJobInformer.Lister(),
+
+		jobListerSynced:     jobInformer.Informer().HasSynced,
+		cronJobListerSynced: cronJobInformer.Informer().HasSynced,
+
+		metrics: newCronJobMetrics(metricsCfg),
+
+		now: time.Now,
+	}
+	jm.metrics.register()
+
+	cronJobInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    jm.enqueueController,
+		UpdateFunc: jm.updateCronJob,
+		DeleteFunc: jm.enqueueController,
+	})
+
+	jobInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    jm.addJob,
+		UpdateFunc: jm.updateJob,
+		DeleteFunc: jm.deleteJob,
+	})
+
+	return jm, nil
+}
+
+// Run starts the main goroutine responsible for watching and syncing jobs.
+func (jm *ControllerV2) Run(ctx context.Context, workers int) {
+	defer utilruntime.HandleCrash()
+	defer jm.queue.ShutDown()
+
+	klog.InfoS("Starting cronjob controller v2")
+	defer klog.InfoS("Shutting down cronjob controller v2")
+
+	if !cache.WaitForNamedCacheSync("cronjob", ctx.Done(), jm.jobListerSynced, jm.cronJobListerSynced) {
+		return
+	}
+
+	go jm.metrics.runResetLoop(ctx.Done())
+
+	for i := 0; i < workers; i++ {
+		go wait.UntilWithContext(ctx, jm.worker, time.Second)
+	}
+
+	<-ctx.Done()
+}
+
+func (jm *ControllerV2) worker(ctx context.Context) {
+	for jm.processNextWorkItem(ctx) {
+	}
+}
+
+func (jm *ControllerV2) processNextWorkItem(ctx context.Context) bool {
+	key, quit := jm.queue.Get()
+	if quit {
+		return false
+	}
+	defer jm.queue.Done(key)
+
+	requeueAfter, err := jm.sync(ctx, key.(string))
+	switch {
+	case err != nil:
+		utilruntime.HandleError(fmt.Errorf("error syncing CronJobController %v, requeuing: %v", key, err))
+		jm.queue.AddRateLimited(key)
+	case requeueAfter != nil:
+		jm.queue.Forget(key)
+		jm.queue.AddAfter(key, *requeueAfter)
+	}
+	return true
+}
+
+func (jm *ControllerV2) sync(ctx context.Context, cronJobKey string) (*time.Duration, error) {
+	ns, name, err := cache.SplitMetaNamespaceKey(cronJobKey)
+	if err != nil {
+		return nil, err
+	}
+
+	cronJob, err := jm.cronJobLister.CronJobs(ns).Get(name)
+	if apierrors.IsNotFound(err) {
+		klog.V(4).InfoS("CronJob not found, may be it is deleted", "cronjob", cronJobKey)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cronJob.DeletionTimestamp == nil && !hasFinalizer(cronJob, cronJobCleanupFinalizer) {
+		updated, err := jm.addFinalizer(cronJob)
+		if err != nil {
+			return nil, err
+		}
+		cronJob = updated
+	}
+
+	jobsToBeReconciled, err := jm.getJobsToBeReconciled(cronJob)
+	if err != nil {
+		return nil, err
+	}
+
+	cronJobCopy, requeueAfter, updateStatus, err := jm.syncCronJob(ctx, cronJob, jobsToBeReconciled)
+	if err != nil {
+		klog.V(2).InfoS("Error reconciling cronjob", "cronjob", klog.KObj(cronJob), "err", err)
+		if updateStatus {
+			if _, err := jm.cronJobControl.UpdateStatus(cronJobCopy); err != nil {
+				klog.V(2).InfoS("Unable to update status for cronjob", "cronjob", klog.KObj(cronJob), "resourceVersion", cronJob.ResourceVersion, "err", err)
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	if jm.cleanupFinishedJobs(ctx, cronJobCopy, jobsToBeReconciled) {
+		updateStatus = true
+	}
+
+	if updateStatus {
+		if _, err := jm.cronJobControl.UpdateStatus(cronJobCopy); err != nil {
+			klog.V(2).InfoS("Unable to update status for cronjob", "cronjob", klog.KObj(cronJob), "resourceVersion", cronJobCopy.ResourceVersion, "err", err)
+			return nil, err
+		}
+	}
+
+	if requeueAfter != nil {
+		klog.V(4).InfoS("Re-queuing cronjob", "cronjob", klog.KObj(cronJob), "requeueAfter", requeueAfter)
+		return requeueAfter, nil
+	}
+	// this marks the key done: syncCronJob asks for no requeue when the schedule is
+	// unparseable, the CronJob is managed by an external controller, or its deletion
+	// needs no further recheck.
+	return nil, nil
+}
+
+// syncCronJob reconciles a CronJob with a list of any Jobs that it created and currently
+// exist. It returns a copy of the CronJob with its status possibly updated, how long to
+// wait before the next reconciliation of this CronJob, whether the status should be
+// persisted, and an error, if any.
+func (jm *ControllerV2) syncCronJob(ctx context.Context, cj *batchv1.CronJob, js []*batchv1.Job) (*batchv1.CronJob, *time.Duration, bool, error) {
+	cj = cj.DeepCopy()
+	now := jm.now()
+	updateStatus := false
+
+	// Account for any jobs we created previously that have since finished: drop them from
+	// Status.Active and, for successful completions, record the most recent success time.
+	for _, j := range js {
+		finished, condition := getFinishedStatus(j)
+		if !finished {
+			continue
+		}
+		if inActiveList(*cj, j.ObjectMeta.UID) {
+			deleteFromActiveList(cj, j.ObjectMeta.UID)
+			updateStatus = true
+		}
+		if condition == batchv1.JobComplete && j.Status.CompletionTime != nil {
+			cj.Status.LastSuccessfulTime = j.Status.CompletionTime
+			updateStatus = true
+		}
+		if jm.jobStateControl != nil {
+			finishTime := j.Status.CompletionTime
+			if finishTime == nil {
+				finishTime = &metav1.Time{Time: now}
+			}
+			if scheduledTime, ok := getScheduledTimeForJob(j); ok {
+				if err := jm.jobStateControl.Save(cj, jobState{
+					ScheduledTime: *scheduledTime,
+					Phase:         jobSchedulePhaseFinished,
+					FinishTime:    &finishTime.Time,
+					Succeeded:     condition == batchv1.JobComplete,
+				}); err != nil {
+					klog.V(2).InfoS("Unable to persist finished job state", "cronjob", klog.KObj(cj), "err", err)
+				}
+			}
+		}
+	}
+
+	updatedActive := []v1.ObjectReference{}
+	for _, j := range cj.Status.Active {
+		// A Job the lister already holds is known to exist; only ask the API server
+		// about the ones the lister has not (yet) seen.
+		inLister := false
+		for _, job := range js {
+			inLister = inLister || job.ObjectMeta.UID == j.UID
+		}
+		if inLister {
+			updatedActive = append(updatedActive, j)
+			continue
+		}
+		_, err := jm.jobControl.GetJob(j.Namespace, j.Name)
+		if err != nil && apierrors.IsNotFound(err) {
+			jm.recorder.Eventf(cj, v1.EventTypeNormal, "MissingJob", "Active job went missing: %v", j.Name)
+			updateStatus = true
+			continue
+		} else if err != nil {
+			return cj, nil, updateStatus, err
+		}
+		updatedActive = append(updatedActive, j)
+	}
+	cj.Status.Active = updatedActive
+
+	if cj.DeletionTimestamp != nil {
+		// The CronJob is being deleted: reconcile its children per spec.cleanupPolicy and
+		// remove cronJobCleanupFinalizer once none are left, instead of falling through to
+		// the regular scheduling logic below.
+		return jm.syncCronJobDeletion(cj, updateStatus)
+	}
+
+	if isManagedByExternalController(cj) {
+		// An external controller owns scheduling decisions for this CronJob; only
+		// reconcile the bookkeeping already done above (e.g. Status.Active, which an
+		// external controller also populates via the Job's owner reference) and leave
+		// scheduling fields such as Status.LastScheduleTime to that controller.
+		jm.recorder.Eventf(cj, v1.EventTypeNormal, reasonDelegatedToManagedBy, "Skipping in-tree scheduling for CronJob managed by %q", cj.Spec.ManagedBy)
+		return cj, nil, updateStatus, nil
+	}
+
+	sched, err := getSchedule(cj)
+	if err != nil {
+		// this is likely a user error in defining the spec, and we should not
+		// automatically retry.
+		recordScheduleError(jm.recorder, cj, err)
+		return cj, nil, updateStatus, nil
+	}
+
+	if triggerTime, ok := triggerRequested(cj); ok {
+		// A manual trigger runs regardless of when spec.schedule next fires and of
+		// spec.suspend, so handle it before the suspend and scheduled-time checks, and
+		// don't fall through to the regular scheduling decision below in the same sync.
+		// An unparseable schedule still stops it, as getSchedule runs first.
+		cj, err = jm.handleManualTrigger(cj, triggerTime)
+		if err != nil {
+			return cj, nil, true, err
+		}
+		return cj, nextScheduledTimeDurationPtr(sched, now), true, nil
+	}
+
+	if cj.Spec.ConcurrencyPolicy == queueConcurrent {
+		if cj.Spec.Suspend != nil && *cj.Spec.Suspend {
+			klog.V(4).InfoS("Not starting job because the cron is suspended", "cronjob", klog.KObj(cj))
+			return cj, nextScheduledTimeDurationPtr(sched, now), updateStatus, nil
+		}
+		return jm.syncQueueConcurrency(cj, sched, now)
+	}
+
+	if cj.Spec.StartingDeadlineSeconds != nil {
+		// getNextScheduleTime skips schedule times older than the starting deadline, so
+		// look them up without the deadline to report the oldest of them as overdue.
+		unbounded := *cj
+		unbounded.Spec.StartingDeadlineSeconds = nil
+		cutoff := now.Add(-time.Second * time.Duration(*cj.Spec.StartingDeadlineSeconds))
+		if missed, err := getRecentUnmetScheduleTimes(unbounded, now); err == nil && len(missed) > 0 && missed[0].Before(cutoff) {
+			jm.metrics.observeOverdue(cj, now.Sub(missed[0]))
+		}
+	}
+
+	scheduledTime, err := getNextScheduleTime(*cj, now)
+	if err != nil {
+		// this is likely a user error in defining the spec, and we should not
+		// automatically retry.
+		recordScheduleError(jm.recorder, cj, err)
+		return cj, nil, updateStatus, nil
+	}
+	if scheduledTime == nil {
+		// no unmet start times, return cj,.
+		// The only time this should happen is if queue is filled after restart.
+		return cj, nextScheduledTimeDurationPtr(sched, now), updateStatus, nil
+	}
+
+	tooLate := false
+	if cj.Spec.StartingDeadlineSeconds != nil {
+		tooLate = scheduledTime.Add(time.Second * time.Duration(*cj.Spec.StartingDeadlineSeconds)).Before(now)
+	}
+	if tooLate {
+		jm.recorder.Eventf(cj, v1.EventTypeWarning, reasonMissSchedule, "Missed scheduled time to start a job: %s", scheduledTime.UTC().Format(time.RFC1123Z))
+		jm.metrics.observeOverdue(cj, now.Sub(*scheduledTime))
+		return cj, nextScheduledTimeDurationPtr(sched, now), updateStatus, nil
+	}
+
+	if cj.Spec.Suspend != nil && *cj.Spec.Suspend {
+		klog.V(4).InfoS("Not starting job because the cron is suspended", "cronjob", klog.KObj(cj))
+		return cj, nextScheduledTimeDurationPtr(sched, now), updateStatus, nil
+	}
+
+	// The Job for this scheduled time may already exist, listed in Status.Active or
+	// already visible in the job lister; don't start another one for it.
+	scheduledJobName := getJobName(cj, *scheduledTime)
+	alreadyScheduled := false
+	for _, j := range cj.Status.Active {
+		alreadyScheduled = alreadyScheduled || (j.Name == scheduledJobName && j.Namespace == cj.Namespace)
+	}
+	for _, j := range js {
+		alreadyScheduled = alreadyScheduled || j.Name == scheduledJobName
+	}
+	if alreadyScheduled {
+		klog.V(4).InfoS("Not starting job because the scheduled time is already processed", "cronjob", klog.KObj(cj), "scheduledTime", scheduledTime)
+		return cj, nextScheduledTimeDurationPtr(sched, now), updateStatus, nil
+	}
+
+	isJobInActiveList := func(job *batchv1.Job, activeJobs []v1.ObjectReference) bool {
+		for _, j := range activeJobs {
+			if j.Name == job.Name && j.Namespace == job.Namespace {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(cj.Status.Active) > 0 && cj.Spec.ConcurrencyPolicy == batchv1.ForbidConcurrent {
+		klog.V(4).InfoS("Not starting job because prior execution is still running and concurrency policy is Forbid", "cronjob", klog.KObj(cj))
+		jm.recorder.Eventf(cj, v1.EventTypeNormal, "JobAlreadyActive", "Not starting job because prior execution is running and concurrency policy is Forbid")
+		return cj, nextScheduledTimeDurationPtr(sched, now), updateStatus, nil
+	}
+	if cj.Spec.ConcurrencyPolicy == batchv1.ReplaceConcurrent {
+		for _, j := range cj.Status.Active {
+			klog.V(4).InfoS("Deleting job that was still running at next scheduled start time", "job", klog.KRef(j.Namespace, j.Name))
+
+			job, err := jm.jobControl.GetJob(j.Namespace, j.Name)
+			if err != nil {
+				jm.recorder.Eventf(cj, v1.EventTypeWarning, reasonFailedDelete, "Could not find job %q", j.Name)
+				return cj, nil, updateStatus, err
+			}
+			if !deleteJob(cj, job, jm.jobControl, jm.recorder) {
+				return cj, nil, updateStatus, fmt.Errorf("could not replace job %q", j.Name)
+			}
+			updateStatus = true
+		}
+	}
+
+	if jm.jobStateControl != nil {
+		state, err := jm.jobStateControl.Get(cj)
+		if err != nil {
+			klog.V(2).InfoS("Unable to read job state, proceeding without crash recovery for this sync", "cronjob", klog.KObj(cj), "err", err)
+		} else if state != nil && state.Phase == jobSchedulePhaseStarted && state.ScheduledTime.Equal(*scheduledTime) {
+			// A previous sync already committed to creating a Job for this scheduled
+			// time but the controller was never told whether that create succeeded
+			// (e.g. it crashed before the Job's ObjectMeta made it into Status.Active).
+			// Don't attempt a second create for the same scheduled time; the next
+			// sync will pick up the Job once it (or its absence) is observable.
+			klog.V(4).InfoS("Scheduled time already has an in-flight or unresolved Job, not creating another", "cronjob", klog.KObj(cj), "scheduledTime", scheduledTime)
+			return cj, nextScheduledTimeDurationPtr(sched, now), updateStatus, nil
+		}
+		if err := jm.jobStateControl.Save(cj, jobState{ScheduledTime: *scheduledTime, Phase: jobSchedulePhaseStarted}); err != nil {
+			klog.V(2).InfoS("Unable to persist in-flight job state before create", "cronjob", klog.KObj(cj), "err", err)
+		}
+	}
+
+	jobReq, err := getJobFromTemplate2(cj, *scheduledTime)
+	if err != nil {
+		klog.ErrorS(err, "Unable to make Job from template", "cronjob", klog.KObj(cj))
+		return cj, nil, updateStatus, err
+	}
+	if jobReq.Annotations == nil {
+		jobReq.Annotations = make(map[string]string, 1)
+	}
+	jobReq.Annotations[jobOriginAnnotation] = jobOriginScheduled
+	jobResp, err := jm.jobControl.CreateJob(cj.Namespace, jobReq)
+	switch {
+	case apierrors.IsAlreadyExists(err):
+		// The Job was already created by another actor (time drift, controller restart):
+		// return the error and leave Status.LastScheduleTime unchanged.
+		klog.V(4).InfoS("Job already exists", "cronjob", klog.KObj(cj), "job", klog.KObj(jobReq))
+		return cj, nil, updateStatus, err
+	case err != nil:
+		jm.recorder.Eventf(cj, v1.EventTypeWarning, reasonFailedCreate, "Error creating job: %v", err)
+		return cj, nil, updateStatus, err
+	}
+
+	klog.V(4).InfoS("Created Job", "job", klog.KObj(jobResp), "cronjob", klog.KObj(cj))
+	jm.recorder.Eventf(cj, v1.EventTypeNormal, reasonSuccessfulCreate, "Created job %v", jobResp.Name)
+	jm.metrics.clearOverdue(cj)
+	jm.metrics.observeScheduleDelay(cj, now.Sub(*scheduledTime))
+
+	jobRef, err := getRef(jobResp)
+	if err != nil {
+		klog.V(2).InfoS("Unable to make object reference", "cronjob", klog.KObj(cj), "err", err)
+		return cj, nextScheduledTimeDurationPtr(sched, now), updateStatus, fmt.Errorf("unable to make object reference for job for %s", klog.KObj(cj))
+	}
+	if !isJobInActiveList(jobResp, cj.Status.Active) {
+		cj.Status.Active = append(cj.Status.Active, *jobRef)
+	}
+	cj.Status.LastScheduleTime = &metav1.Time{Time: *scheduledTime}
+	updateStatus = true
+
+	return cj, nextScheduledTimeDurationPtr(sched, now), updateStatus, nil
+}
+
+func nextScheduledTimeDurationPtr(sched cron.Schedule, now time.Time) *time.Duration {
+	return nextScheduledTimeDuration(sched, now)
+}
+
+// nextScheduledTimeDuration returns the time duration to requeue based on
+// the next schedule after now.
+func nextScheduledTimeDuration(sched cron.Schedule, now time.Time) *time.Duration {
+	t := sched.Next(now).Add(nextScheduleDelta).Sub(now)
+	return &t
+}
+
+// cleanupFinishedJobs cleans up any successful finished jobs for the cronjob, if
+// successfulJobsHistoryLimit/failedJobsHistoryLimit is reached. It reports whether
+// the cronjob status has been updated as a result.
+func (jm *ControllerV2) cleanupFinishedJobs(ctx context.Context, cj *batchv1.CronJob, js []*batchv1.Job) bool {
+	if cj.Spec.FailedJobsHistoryLimit == nil && cj.Spec.SuccessfulJobsHistoryLimit == nil {
+		return false
+	}
+
+	var successfulJobs, failedJobs []*batchv1.Job
+	for _, job := range js {
+		isFinished, finishedStatus := getFinishedStatus(job)
+		if !isFinished {
+			continue
+		}
+		if finishedStatus == batchv1.JobComplete {
+			successfulJobs = append(successfulJobs, job)
+		} else if finishedStatus == batchv1.JobFailed {
+			failedJobs = append(failedJobs, job)
+		}
+	}
+
+	updateStatus := false
+	if cj.Spec.SuccessfulJobsHistoryLimit != nil &&
+		jm.removeOldestJobs(ctx, cj, successfulJobs, *cj.Spec.SuccessfulJobsHistoryLimit) {
+		updateStatus = true
+	}
+	if cj.Spec.FailedJobsHistoryLimit != nil &&
+		jm.removeOldestJobs(ctx, cj, failedJobs, *cj.Spec.FailedJobsHistoryLimit) {
+		updateStatus = true
+	}
+	return updateStatus
+}
+
+// removeOldestJobs deletes the oldest of js, by scheduled time, down to maxJobs, and
+// reports whether any were deleted.
+func (jm *ControllerV2) removeOldestJobs(ctx context.Context, cj *batchv1.CronJob, js []*batchv1.Job, maxJobs int32) bool {
+	numToDelete := len(js) - int(maxJobs)
+	if numToDelete <= 0 {
+		return false
+	}
+	klog.V(4).InfoS("Cleaning up old jobs", "cronjob", klog.KObj(cj), "numToDelete", numToDelete)
+
+	sort.Sort(byScheduleTime(js))
+	updateStatus := false
+	for i := 0; i < numToDelete; i++ {
+		klog.V(4).InfoS("Removing job", "cronjob", klog.KObj(cj), "job", klog.KObj(js[i]))
+		if deleteJob(cj, js[i], jm.jobControl, jm.recorder) {
+			updateStatus = true
+		}
+	}
+	return updateStatus
+}
+
+func getFinishedStatus(j *batchv1.Job) (bool, batchv1.JobConditionType) {
+	for _, c := range j.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == v1.ConditionTrue {
+			return true, c.Type
+		}
+	}
+	return false, ""
+}
+
+// IsJobFinished reports whether a job has completed or failed.
+func IsJobFinished(j *batchv1.Job) bool {
+	isFinished, _ := getFinishedStatus(j)
+	return isFinished
+}
+
+func deleteJob(cj *batchv1.CronJob, job *batchv1.Job, jc jobControlInterface, recorder record.EventRecorder) bool {
+	nameForLog := fmt.Sprintf("%s/%s", job.Namespace, job.Name)
+	if err := jc.DeleteJob(job.Namespace, job.Name); err != nil {
+		deleteFromActiveList(cj, job.ObjectMeta.UID)
+		recorder.Eventf(cj, v1.EventTypeWarning, reasonFailedDelete, "Deleted job %v", nameForLog)
+		return false
+	}
+	deleteFromActiveList(cj, job.ObjectMeta.UID)
+	recorder.Eventf(cj, v1.EventTypeNormal, reasonSuccessfulDelete, "Deleted job %v", nameForLog)
+	return true
+}
+
+// enqueueController enqueues the given CronJob in the work queue.
+func (jm *ControllerV2) enqueueController(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
+		return
+	}
+	jm.queue.Add(key)
+}
+
+func (jm *ControllerV2) enqueueControllerAfter(obj interface{}, t time.Duration) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
+		return
+	}
+	jm.queue.AddAfter(key, t)
+}
+
+// updateCronJob re-queues the CronJob for its next scheduled time if spec.schedule,
+// spec.timePlan or spec.timeZone has changed, and right away if a new manual trigger was
+// requested. Any other change leaves the CronJob queued as it already was.
+func (jm *ControllerV2) updateCronJob(old, curr interface{}) {
+	oldCJ, okOld := old.(*batchv1.CronJob)
+	newCJ, okNew := curr.(*batchv1.CronJob)
+
+	if !okOld || !okNew {
+		return
+	}
+	// if the change in schedule results in next sync action being different, needs to
+	// re-queue the cronjob immediately. A changed spec.timeZone (covering the legacy
+	// CRON_TZ=/TZ= inline prefix too, since that lives inside spec.Schedule) shifts the
+	// next scheduled time just as much as the cron expression itself does.
+	if oldCJ.Spec.Schedule != newCJ.Spec.Schedule ||
+		!reflect.DeepEqual(oldCJ.Spec.TimePlan, newCJ.Spec.TimePlan) ||
+		!reflect.DeepEqual(oldCJ.Spec.TimeZone, newCJ.Spec.TimeZone) {
+		sched, err := getSchedule(newCJ)
+		if err != nil {
+			// The new schedule is unparseable; do nothing.
+			klog.V(2).InfoS("Unparseable schedule for cronjob", "cronjob", klog.KObj(newCJ), "schedule", newCJ.Spec.Schedule, "err", err)
+			return
+		}
+		now := jm.now()
+		t := nextScheduledTimeDuration(sched, now)
+		jm.enqueueControllerAfter(curr, *t)
+		return
+	}
+
+	// A new manual-trigger request should run as soon as possible rather than waiting
+	// for the next regularly scheduled time.
+	if oldCJ.Annotations[triggerAnnotation] != newCJ.Annotations[triggerAnnotation] {
+		if _, ok := triggerRequested(newCJ); ok {
+			jm.enqueueController(curr)
+			return
+		}
+	}
+
+	// no change in schedule, the CronJob will still be processed at the same time
+}
+
+func (jm *ControllerV2) addJob(obj interface{}) {
+	job := obj.(*batchv1.Job)
+	if job.DeletionTimestamp != nil {
+		return
+	}
+	if controllerRef := metav1.GetControllerOf(job); controllerRef != nil {
+		cj := jm.resolveControllerRef(job.Namespace, controllerRef)
+		if cj == nil {
+			return
+		}
+		jm.enqueueController(cj)
+		return
+	}
+}
+
+func (jm *ControllerV2) updateJob(old, cur interface{}) {
+	curJob := cur.(*batchv1.Job)
+	oldJob := old.(*batchv1.Job)
+	if curJob.ResourceVersion == oldJob.ResourceVersion {
+		return
+	}
+	jm.addJob(cur)
+}
+
+func (jm *ControllerV2) deleteJob(obj interface{}) {
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		job, ok = tombstone.Obj.(*batchv1.Job)
+		if !ok {
+			return
+		}
+	}
+	jm.addJob(job)
+}
+
+func (jm *ControllerV2) resolveControllerRef(namespace string, controllerRef *metav1.OwnerReference) *batchv1.CronJob {
+	if controllerRef.Kind != controllerKind.Kind {
+		return nil
+	}
+	cj, err := jm.cronJobLister.CronJobs(namespace).Get(controllerRef.Name)
+	if err != nil {
+		return nil
+	}
+	if cj.UID != controllerRef.UID {
+		return nil
+	}
+	return cj
+}
+
+// getJobsToBeReconciled returns all the Jobs owned by the given CronJob, as found in the
+// shared job lister/indexer.
+func (jm *ControllerV2) getJobsToBeReconciled(cronJob *batchv1.CronJob) ([]*batchv1.Job, error) {
+	jobList, err := jm.jobLister.Jobs(cronJob.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	jobsToBeReconciled := []*batchv1.Job{}
+
+	for _, job := range jobList {
+		if owner := metav1.GetControllerOf(job); owner != nil && owner.Name == cronJob.Name {
+			jobsToBeReconciled = append(jobsToBeReconciled, job)
+		}
+	}
+
+	sort.Sort(byScheduleTime(jobsToBeReconciled))
+
+	return jobsToBeReconciled, nil
+}
+
+func getScheduledTimeForJob(j *batchv1.Job) (*time.Time, bool) {
+	if j.ObjectMeta.CreationTimestamp.IsZero() {
+		return nil, false
+	}
+	t := j.ObjectMeta.CreationTimestamp.Time
+	return &t, true
+}
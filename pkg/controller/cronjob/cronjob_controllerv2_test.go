@@ -18,16 +18,16 @@ package cronjob
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
 
-	"github.com/robfig/cron/v3"
-
 	batchv1 "k8s.io/api/batch/v1"
 	"k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -36,6 +36,7 @@ import (
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
+	"k8s.io/component-base/metrics/testutil"
 	_ "k8s.io/kubernetes/pkg/apis/batch/install"
 	_ "k8s.io/kubernetes/pkg/apis/core/install"
 	"k8s.io/kubernetes/pkg/controller"
@@ -52,6 +53,33 @@ var (
 	onTheHour = "0 * * * ?"
 )
 
+// onTheHourTimePlan is a TimePlan equivalent to the onTheHour cron expression: every
+// day, every hour, only at minute 0.
+func onTheHourTimePlan() *batchv1.TimePlan {
+	plan := &batchv1.TimePlan{}
+	for i := range plan.Days {
+		plan.Days[i] = true
+	}
+	for i := range plan.Hours {
+		plan.Hours[i] = true
+	}
+	plan.Minutes[0] = true
+	return plan
+}
+
+// errorTimePlan has no minutes enabled, which validateTimePlan rejects the same way
+// cron.ParseStandard rejects errorSchedule.
+func errorTimePlan() *batchv1.TimePlan {
+	plan := &batchv1.TimePlan{}
+	for i := range plan.Days {
+		plan.Days[i] = true
+	}
+	for i := range plan.Hours {
+		plan.Hours[i] = true
+	}
+	return plan
+}
+
 // returns a cronJob with some fields filled in.
 func cronJob() batchv1.CronJob {
 	return batchv1.CronJob{
@@ -162,7 +190,11 @@ func TestControllerV2SyncCronJob(t *testing.T) {
 		concurrencyPolicy batchv1.ConcurrencyPolicy
 		suspend           bool
 		schedule          string
+		timePlan          *batchv1.TimePlan
+		timeZone          string
 		deadline          int64
+		managedBy         string
+		trigger           bool
 
 		// cj status
 		ranPreviously bool
@@ -212,6 +244,29 @@ func TestControllerV2SyncCronJob(t *testing.T) {
 			expectedWarnings:           1,
 			jobPresentInCJActiveStatus: true,
 		},
+		"never ran, invalid time zone, A": {
+			concurrencyPolicy:          "Allow",
+			schedule:                   onTheHour,
+			timeZone:                   "Not/AZone",
+			deadline:                   noDead,
+			jobCreationTime:            justAfterThePriorHour(),
+			now:                        justBeforeTheHour(),
+			expectedWarnings:           1,
+			jobPresentInCJActiveStatus: true,
+		},
+		"never ran, is time, America/New_York, A": {
+			concurrencyPolicy:          "Allow",
+			schedule:                   onTheHour,
+			timeZone:                   "America/New_York",
+			deadline:                   noDead,
+			jobCreationTime:            justAfterThePriorHour(),
+			now:                        *justAfterTheHour(),
+			expectCreate:               true,
+			expectActive:               1,
+			expectRequeueAfter:         true,
+			expectUpdateStatus:         true,
+			jobPresentInCJActiveStatus: true,
+		},
 		"never ran, not time, A": {
 			concurrencyPolicy:          "Allow",
 			schedule:                   onTheHour,
@@ -274,6 +329,49 @@ func TestControllerV2SyncCronJob(t *testing.T) {
 			expectUpdateStatus:         true,
 			jobPresentInCJActiveStatus: true,
 		},
+		"never ran, not valid timeplan, A": {
+			concurrencyPolicy:          "Allow",
+			timePlan:                   errorTimePlan(),
+			deadline:                   noDead,
+			jobCreationTime:            justAfterThePriorHour(),
+			now:                        justBeforeTheHour(),
+			expectedWarnings:           1,
+			jobPresentInCJActiveStatus: true,
+		},
+		"never ran, is time, timeplan on the hour, A": {
+			concurrencyPolicy:          "Allow",
+			timePlan:                   onTheHourTimePlan(),
+			deadline:                   noDead,
+			jobCreationTime:            justAfterThePriorHour(),
+			now:                        *justAfterTheHour(),
+			expectCreate:               true,
+			expectActive:               1,
+			expectRequeueAfter:         true,
+			expectUpdateStatus:         true,
+			jobPresentInCJActiveStatus: true,
+		},
+		"never ran, is time, reserved managedBy, A": {
+			concurrencyPolicy:          "Allow",
+			schedule:                   onTheHour,
+			deadline:                   noDead,
+			managedBy:                  defaultManagedBy,
+			jobCreationTime:            justAfterThePriorHour(),
+			now:                        *justAfterTheHour(),
+			expectCreate:               true,
+			expectActive:               1,
+			expectRequeueAfter:         true,
+			expectUpdateStatus:         true,
+			jobPresentInCJActiveStatus: true,
+		},
+		"never ran, is time, third-party managedBy, A": {
+			concurrencyPolicy:          "Allow",
+			schedule:                   onTheHour,
+			deadline:                   noDead,
+			managedBy:                  "example.com/custom-controller",
+			jobCreationTime:            justAfterThePriorHour(),
+			now:                        *justAfterTheHour(),
+			jobPresentInCJActiveStatus: true,
+		},
 		"never ran, is time, suspended": {
 			concurrencyPolicy:          "Allow",
 			suspend:                    true,
@@ -358,7 +456,7 @@ func TestControllerV2SyncCronJob(t *testing.T) {
 			ranPreviously:              true,
 			jobCreationTime:            justAfterThePriorHour(),
 			now:                        *justAfterTheHour(),
-			jobCreateError:             errors.NewAlreadyExists(schema.GroupResource{Resource: "job", Group: "batch"}, ""),
+			jobCreateError:             apierrors.NewAlreadyExists(schema.GroupResource{Resource: "job", Group: "batch"}, ""),
 			expectErr:                  true,
 			expectUpdateStatus:         true,
 			jobPresentInCJActiveStatus: true,
@@ -510,7 +608,7 @@ func TestControllerV2SyncCronJob(t *testing.T) {
 			stillActive:                true,
 			jobCreationTime:            justAfterThePriorHour(),
 			now:                        *justAfterTheHour(),
-			jobGetErr:                  errors.NewBadRequest("request is invalid"),
+			jobGetErr:                  apierrors.NewBadRequest("request is invalid"),
 			expectActive:               1,
 			expectedWarnings:           1,
 			jobPresentInCJActiveStatus: true,
@@ -731,7 +829,7 @@ func TestControllerV2SyncCronJob(t *testing.T) {
 			ranPreviously:      true,
 			jobCreationTime:    *justAfterTheHour(),
 			now:                justBeforeTheHour(),
-			jobCreateError:     errors.NewAlreadyExists(schema.GroupResource{Resource: "jobs", Group: "batch"}, ""),
+			jobCreateError:     apierrors.NewAlreadyExists(schema.GroupResource{Resource: "jobs", Group: "batch"}, ""),
 			expectRequeueAfter: true,
 			expectUpdateStatus: true,
 		},
@@ -811,6 +909,77 @@ func TestControllerV2SyncCronJob(t *testing.T) {
 			expectActive:       1,
 			expectRequeueAfter: true,
 		},
+
+		// Tests for the manual trigger annotation.
+		"triggered, still active, F": {
+			concurrencyPolicy:          "Forbid",
+			schedule:                   onTheHour,
+			deadline:                   noDead,
+			trigger:                    true,
+			ranPreviously:              true,
+			stillActive:                true,
+			jobCreationTime:            justAfterThePriorHour(),
+			now:                        justBeforeTheHour(),
+			expectActive:               1,
+			expectRequeueAfter:         true,
+			expectUpdateStatus:         true,
+			jobPresentInCJActiveStatus: true,
+		},
+		"triggered, still active, R": {
+			concurrencyPolicy:          "Replace",
+			schedule:                   onTheHour,
+			deadline:                   noDead,
+			trigger:                    true,
+			ranPreviously:              true,
+			stillActive:                true,
+			jobCreationTime:            justAfterThePriorHour(),
+			now:                        justBeforeTheHour(),
+			expectCreate:               true,
+			expectDelete:               true,
+			expectActive:               1,
+			expectRequeueAfter:         true,
+			expectUpdateStatus:         true,
+			jobPresentInCJActiveStatus: true,
+		},
+		"triggered, still active, A": {
+			concurrencyPolicy:          "Allow",
+			schedule:                   onTheHour,
+			deadline:                   noDead,
+			trigger:                    true,
+			ranPreviously:              true,
+			stillActive:                true,
+			jobCreationTime:            justAfterThePriorHour(),
+			now:                        justBeforeTheHour(),
+			expectCreate:               true,
+			expectActive:               2,
+			expectRequeueAfter:         true,
+			expectUpdateStatus:         true,
+			jobPresentInCJActiveStatus: true,
+		},
+		"triggered, suspended, never ran": {
+			concurrencyPolicy:          "Allow",
+			suspend:                    true,
+			schedule:                   onTheHour,
+			deadline:                   noDead,
+			trigger:                    true,
+			now:                        justBeforeTheHour(),
+			expectCreate:               true,
+			expectActive:               1,
+			expectRequeueAfter:         true,
+			expectUpdateStatus:         true,
+			jobPresentInCJActiveStatus: true,
+		},
+		"triggered, not suspended, never ran": {
+			concurrencyPolicy:  "Allow",
+			schedule:           onTheHour,
+			deadline:           noDead,
+			trigger:            true,
+			now:                justBeforeTheHour(),
+			expectCreate:       true,
+			expectActive:       1,
+			expectRequeueAfter: true,
+			expectUpdateStatus: true,
+		},
 	}
 	for name, tc := range testCases {
 		name := name
@@ -820,9 +989,17 @@ func TestControllerV2SyncCronJob(t *testing.T) {
 			cj.Spec.ConcurrencyPolicy = tc.concurrencyPolicy
 			cj.Spec.Suspend = &tc.suspend
 			cj.Spec.Schedule = tc.schedule
+			cj.Spec.TimePlan = tc.timePlan
+			cj.Spec.ManagedBy = tc.managedBy
+			if tc.timeZone != "" {
+				cj.Spec.TimeZone = &tc.timeZone
+			}
 			if tc.deadline != noDead {
 				cj.Spec.StartingDeadlineSeconds = &tc.deadline
 			}
+			if tc.trigger {
+				cj.Annotations = map[string]string{triggerAnnotation: tc.now.Format(time.RFC3339)}
+			}
 
 			var (
 				job *batchv1.Job
@@ -885,7 +1062,7 @@ func TestControllerV2SyncCronJob(t *testing.T) {
 				t.Errorf("%s: expected error got none with requeueAfter time: %#v", name, requeueAfter)
 			}
 			if tc.expectRequeueAfter {
-				sched, err := cron.ParseStandard(tc.schedule)
+				sched, err := getSchedule(&cj)
 				if err != nil {
 					t.Errorf("%s: test setup error: the schedule %s is unparseable: %#v", name, tc.schedule, err)
 				}
@@ -956,6 +1133,15 @@ func TestControllerV2SyncCronJob(t *testing.T) {
 				// this is the only test case where we would raise an event for not scheduling
 				expectedEvents++
 			}
+			if tc.managedBy != "" && tc.managedBy != defaultManagedBy {
+				// delegating to an external controller raises one informational event
+				expectedEvents++
+			}
+			if tc.trigger && !tc.expectCreate {
+				// a trigger refused by a Forbid concurrency policy still raises a
+				// JobAlreadyActive event, same as a regular scheduled run would
+				expectedEvents++
+			}
 			expectedEvents += tc.expectedWarnings
 
 			if len(recorder.Events) != expectedEvents {
@@ -985,6 +1171,440 @@ func TestControllerV2SyncCronJob(t *testing.T) {
 
 }
 
+// TestControllerV2SyncCronJobResumesAfterCrash verifies that if a sync marks a
+// scheduled time "Started" in job state but the controller is restarted before the
+// resulting Job is ever observed (simulating a crash mid-create), the next sync for
+// that same scheduled time does not attempt a second Job creation.
+func TestControllerV2SyncCronJobResumesAfterCrash(t *testing.T) {
+	cj := cronJob()
+	cj.Spec.Schedule = onTheHour
+	now := *justAfterTheHour()
+
+	jc := &fakeJobControl{CreateErr: fmt.Errorf("simulated create failure")}
+	cjc := &fakeCJControl{CronJob: cj.DeepCopy()}
+	jsc := &fakeJobStateControl{}
+	recorder := record.NewFakeRecorder(10)
+
+	jm := ControllerV2{
+		jobControl:      jc,
+		cronJobControl:  cjc,
+		jobStateControl: jsc,
+		recorder:        recorder,
+		now:             func() time.Time { return now },
+	}
+
+	if _, _, _, err := jm.syncCronJob(context.TODO(), &cj, nil); err == nil {
+		t.Fatalf("expected the simulated create failure to surface as an error")
+	}
+	state, err := jsc.Get(&cj)
+	if err != nil || state == nil || state.Phase != jobSchedulePhaseStarted {
+		t.Fatalf("expected job state to be marked Started after the failed create, got %#v, err %v", state, err)
+	}
+
+	// Simulate a restart: a fresh ControllerV2 whose jobControl would now happily
+	// create a job, but which must first consult the persisted state.
+	jc2 := &fakeJobControl{}
+	jm2 := ControllerV2{
+		jobControl:      jc2,
+		cronJobControl:  cjc,
+		jobStateControl: jsc,
+		recorder:        recorder,
+		now:             func() time.Time { return now },
+	}
+	if _, _, _, err := jm2.syncCronJob(context.TODO(), &cj, nil); err != nil {
+		t.Fatalf("unexpected error resuming sync: %v", err)
+	}
+	if len(jc2.Jobs) != 0 {
+		t.Errorf("expected no new job to be created for the already-Started scheduled time, got %d", len(jc2.Jobs))
+	}
+}
+
+// TestControllerV2ManualTriggerDedupesAcrossRestart verifies that a manual-trigger
+// annotation only ever produces one Job, even if the controller restarts before the
+// annotation is removed by whoever requested the trigger: the second sync must recognize
+// triggerAnnotation's timestamp no longer postdates the persisted Status.LastTriggerTime.
+func TestControllerV2ManualTriggerDedupesAcrossRestart(t *testing.T) {
+	cj := cronJob()
+	suspend := true
+	cj.Spec.Suspend = &suspend // isolate the manual trigger from the regular schedule
+	cj.Spec.Schedule = onTheHour
+	triggerTime := justBeforeTheHour()
+	cj.Annotations = map[string]string{triggerAnnotation: triggerTime.Format(time.RFC3339)}
+
+	jc := &fakeJobControl{}
+	cjc := &fakeCJControl{CronJob: cj.DeepCopy()}
+	recorder := record.NewFakeRecorder(10)
+
+	jm := ControllerV2{
+		jobControl:     jc,
+		cronJobControl: cjc,
+		recorder:       recorder,
+		now:            func() time.Time { return triggerTime },
+	}
+
+	cjCopy, _, _, err := jm.syncCronJob(context.TODO(), &cj, nil)
+	if err != nil {
+		t.Fatalf("unexpected error on first sync: %v", err)
+	}
+	if len(jc.Jobs) != 1 {
+		t.Fatalf("expected one job to be created for the trigger, got %d", len(jc.Jobs))
+	}
+	if cjCopy.Status.LastTriggerTime == nil || !cjCopy.Status.LastTriggerTime.Time.Equal(triggerTime) {
+		t.Fatalf("expected Status.LastTriggerTime %v, got %#v", triggerTime, cjCopy.Status.LastTriggerTime)
+	}
+
+	// Simulate a restart: a fresh ControllerV2 re-syncing the same CronJob, whose
+	// annotation was never cleared by the requester.
+	jc2 := &fakeJobControl{}
+	jm2 := ControllerV2{
+		jobControl:     jc2,
+		cronJobControl: cjc,
+		recorder:       recorder,
+		now:            func() time.Time { return triggerTime },
+	}
+	if _, _, _, err := jm2.syncCronJob(context.TODO(), cjCopy, nil); err != nil {
+		t.Fatalf("unexpected error resuming sync: %v", err)
+	}
+	if len(jc2.Jobs) != 0 {
+		t.Errorf("expected no second job for the same trigger request, got %d", len(jc2.Jobs))
+	}
+}
+
+// TestControllerV2SyncCronJobMetrics verifies that a missed schedule past its starting
+// deadline is reflected in oldestMissedScheduleAgeSeconds, and that a successful create
+// clears that gauge and records the delay in scheduleDelaySeconds.
+func TestControllerV2SyncCronJobMetrics(t *testing.T) {
+	cj := cronJob()
+	cj.Spec.Schedule = onTheHour
+	deadline := shortDead
+	cj.Spec.StartingDeadlineSeconds = &deadline
+	now := justAfterTheHour().Add(time.Minute * time.Duration(shortDead+1))
+
+	m := newCronJobMetrics(DefaultMetricsConfig())
+	jm := ControllerV2{
+		jobControl:     &fakeJobControl{},
+		cronJobControl: &fakeCJControl{CronJob: cj.DeepCopy()},
+		recorder:       record.NewFakeRecorder(10),
+		metrics:        m,
+		now:            func() time.Time { return now },
+	}
+
+	if _, _, _, err := jm.syncCronJob(context.TODO(), &cj, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if age := testutil.ToFloat64(m.oldestMissedScheduleAgeSeconds.WithLabelValues(cj.Namespace, cj.Name)); age <= 0 {
+		t.Errorf("expected oldestMissedScheduleAgeSeconds to be recorded, got %v", age)
+	}
+
+	// Now simulate the same CronJob being reconciled before its deadline, so the
+	// scheduled time is still creatable: the overdue gauge should clear and the
+	// schedule delay should be observed.
+	cj2 := cronJob()
+	cj2.Spec.Schedule = onTheHour
+	now2 := *justAfterTheHour()
+	jm2 := ControllerV2{
+		jobControl:     &fakeJobControl{},
+		cronJobControl: &fakeCJControl{CronJob: cj2.DeepCopy()},
+		recorder:       record.NewFakeRecorder(10),
+		metrics:        m,
+		now:            func() time.Time { return now2 },
+	}
+	if _, _, _, err := jm2.syncCronJob(context.TODO(), &cj2, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count := testutil.CollectAndCount(m.oldestMissedScheduleAgeSeconds, "oldest_missed_schedule_age_seconds"); count != 0 {
+		t.Errorf("expected the overdue gauge for this CronJob to be cleared by the create, got %d samples", count)
+	}
+	if count := testutil.CollectAndCount(m.scheduleDelaySeconds, "schedule_delay_seconds"); count != 1 {
+		t.Errorf("expected one schedule delay observation to be recorded, got %d", count)
+	}
+}
+
+// TestGetScheduleTimeZoneDST verifies that a schedule evaluated in a DST-observing time
+// zone skips forward over a spring-forward gap and does not double-fire across a
+// fall-back repeat.
+func TestGetScheduleTimeZoneDST(t *testing.T) {
+	tz := "America/New_York"
+	cj := cronJob()
+	cj.Spec.Schedule = "30 2 * * ?" // 02:30 local, every day
+	cj.Spec.TimeZone = &tz
+	sched, err := getSchedule(&cj)
+	if err != nil {
+		t.Fatalf("unexpected error resolving schedule: %v", err)
+	}
+
+	// Spring-forward: on 2023-03-12, America/New_York clocks jump from 01:59:59 EST
+	// straight to 03:00:00 EDT, so the local time 02:30 never occurs. The next schedule
+	// from just before midnight should land on the following existing local time, not
+	// get stuck looking for a wall-clock time that doesn't exist. (00:00 UTC is 19:00 local
+	// on 2023-03-11.)
+	before := time.Date(2023, 3, 12, 0, 0, 0, 0, time.UTC)
+	next := sched.Next(before)
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		t.Fatalf("unexpected error loading location: %v", err)
+	}
+	local := next.In(loc)
+	if local.Day() != 12 || (local.Hour() != 2 && local.Hour() != 3) {
+		t.Errorf("expected the schedule to land on the existing local time nearest 02:30 on 2023-03-12, got %v", local)
+	}
+
+	// Fall-back: on 2023-11-05, 01:30 EDT occurs, then clocks fall back and 01:30 EST
+	// occurs again an hour later. Advancing from the first occurrence must land on the
+	// following day, not refire for the repeated local 01:30.
+	cj.Spec.Schedule = "30 1 * * ?"
+	sched, err = getSchedule(&cj)
+	if err != nil {
+		t.Fatalf("unexpected error resolving schedule: %v", err)
+	}
+	firstOccurrence := time.Date(2023, 11, 5, 5, 30, 0, 0, time.UTC) // 01:30 EDT
+	afterFirst := sched.Next(firstOccurrence)
+	if afterFirst.Year() != 2023 || afterFirst.Month() != time.November || afterFirst.Day() != 6 {
+		t.Errorf("expected the next schedule after the first 01:30 occurrence to be the following day, got %v", afterFirst)
+	}
+}
+
+// TestGetScheduleCronTZPrefix verifies the legacy CRON_TZ=/TZ= inline schedule prefix
+// alongside spec.timeZone: the prefix alone resolves the same DST-safe schedule as
+// spec.timeZone, setting both is rejected, and an unknown zone name is reported as an
+// invalidTimeZoneError (so the caller can emit the InvalidTimeZone event reason) rather
+// than folded indistinguishably into a generic unparseable-schedule error.
+func TestGetScheduleCronTZPrefix(t *testing.T) {
+	cj := cronJob()
+	cj.Spec.Schedule = "CRON_TZ=America/New_York 30 2 * * ?"
+	sched, err := getSchedule(&cj)
+	if err != nil {
+		t.Fatalf("unexpected error resolving schedule: %v", err)
+	}
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("unexpected error loading location: %v", err)
+	}
+	before := time.Date(2023, 3, 12, 0, 0, 0, 0, time.UTC)
+	local := sched.Next(before).In(loc)
+	if local.Day() != 12 || (local.Hour() != 2 && local.Hour() != 3) {
+		t.Errorf("expected the schedule to land on the existing local time nearest 02:30 on 2023-03-12, got %v", local)
+	}
+
+	// The legacy "TZ=" spelling is equivalent to "CRON_TZ=".
+	cjLegacy := cronJob()
+	cjLegacy.Spec.Schedule = "TZ=America/New_York 30 2 * * ?"
+	schedLegacy, err := getSchedule(&cjLegacy)
+	if err != nil {
+		t.Fatalf("unexpected error resolving schedule with legacy TZ= prefix: %v", err)
+	}
+	if !schedLegacy.Next(before).Equal(sched.Next(before)) {
+		t.Errorf("expected TZ= and CRON_TZ= to resolve identically, got %v vs %v", schedLegacy.Next(before), sched.Next(before))
+	}
+
+	// Setting both spec.timeZone and an inline prefix is ambiguous and must be rejected.
+	tz := "America/Los_Angeles"
+	cjMismatch := cronJob()
+	cjMismatch.Spec.Schedule = "CRON_TZ=America/New_York 30 2 * * ?"
+	cjMismatch.Spec.TimeZone = &tz
+	if _, err := getSchedule(&cjMismatch); err == nil {
+		t.Errorf("expected an error when spec.timeZone and an inline CRON_TZ= prefix disagree")
+	} else {
+		var tzErr *invalidTimeZoneError
+		if !errors.As(err, &tzErr) {
+			t.Errorf("expected an invalidTimeZoneError, got %T: %v", err, err)
+		}
+	}
+
+	// An unresolvable zone name, whether from the prefix or spec.timeZone, is reported as
+	// an invalidTimeZoneError rather than a generic parse error.
+	cjBadPrefix := cronJob()
+	cjBadPrefix.Spec.Schedule = "CRON_TZ=Not/AZone 30 2 * * ?"
+	if _, err := getSchedule(&cjBadPrefix); err == nil {
+		t.Errorf("expected an error for an unknown time zone in the CRON_TZ= prefix")
+	} else {
+		var tzErr *invalidTimeZoneError
+		if !errors.As(err, &tzErr) {
+			t.Errorf("expected an invalidTimeZoneError, got %T: %v", err, err)
+		}
+	}
+
+	// A prefix with no zone name at all must be rejected too, rather than silently
+	// falling back to UTC as if the prefix had never been written.
+	cjEmptyPrefix := cronJob()
+	cjEmptyPrefix.Spec.Schedule = "CRON_TZ= 30 2 * * ?"
+	if _, err := getSchedule(&cjEmptyPrefix); err == nil {
+		t.Errorf("expected an error for a CRON_TZ= prefix with an empty zone name")
+	} else {
+		var tzErr *invalidTimeZoneError
+		if !errors.As(err, &tzErr) {
+			t.Errorf("expected an invalidTimeZoneError, got %T: %v", err, err)
+		}
+	}
+}
+
+// TestControllerV2SyncCronJobRecordsInvalidTimeZoneEvent verifies that syncCronJob emits
+// the InvalidTimeZone event reason, not the generic InvalidSchedule reason, when the
+// schedule fails to resolve specifically because of its time zone.
+func TestControllerV2SyncCronJobRecordsInvalidTimeZoneEvent(t *testing.T) {
+	cj := cronJob()
+	cj.Spec.Schedule = "CRON_TZ=Not/AZone 30 2 * * ?"
+	recorder := record.NewFakeRecorder(10)
+	jm := ControllerV2{
+		cronJobControl: &fakeCJControl{CronJob: cj.DeepCopy()},
+		recorder:       recorder,
+		now:            func() time.Time { return justBeforeTheHour() },
+	}
+
+	if _, _, _, err := jm.syncCronJob(context.TODO(), &cj, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case e := <-recorder.Events:
+		if !strings.Contains(e, reasonInvalidTimeZone) {
+			t.Errorf("expected event reason %q, got %q", reasonInvalidTimeZone, e)
+		}
+	default:
+		t.Fatalf("expected an event to be recorded")
+	}
+}
+
+// TestControllerV2SyncCronJobQueueConcurrency covers spec.concurrencyPolicy: Queue, which
+// buffers schedule times that would otherwise be dropped (Forbid) or run concurrently
+// (Allow) into Status.PendingSchedules and replays them once a Job slot frees up. This is
+// kept as a dedicated test rather than added to TestControllerV2SyncCronJob's table
+// because its inputs (pre-seeded PendingSchedules, MaxQueuedRuns) don't fit that table's
+// single-scheduledTime model.
+func TestControllerV2SyncCronJobQueueConcurrency(t *testing.T) {
+	t.Run("overflow drops the oldest pending entries with a warning event", func(t *testing.T) {
+		cj := cronJob()
+		cj.Spec.ConcurrencyPolicy = queueConcurrent
+		cj.Spec.Schedule = "* * * * ?"
+		maxQueued := int32(2)
+		cj.Spec.MaxQueuedRuns = &maxQueued
+		now := justAfterTheHour().Add(5 * time.Minute)
+		cj.Status.LastScheduleTime = &metav1.Time{Time: now.Add(-5 * time.Minute)}
+
+		jc := &fakeJobControl{}
+		cjc := &fakeCJControl{CronJob: cj.DeepCopy()}
+		recorder := record.NewFakeRecorder(10)
+		jm := ControllerV2{
+			jobControl:     jc,
+			cronJobControl: cjc,
+			recorder:       recorder,
+			now:            func() time.Time { return now },
+		}
+
+		cjCopy, _, updateStatus, err := jm.syncCronJob(context.TODO(), &cj, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !updateStatus {
+			t.Errorf("expected updateStatus")
+		}
+		if len(jc.Jobs) != 1 {
+			t.Fatalf("expected exactly one job created, got %d", len(jc.Jobs))
+		}
+		if len(cjCopy.Status.PendingSchedules) != 1 {
+			t.Errorf("expected 1 entry left pending after popping the head of a queue capped at %d, got %d", maxQueued, len(cjCopy.Status.PendingSchedules))
+		}
+
+		n := len(recorder.Events)
+		sawOverflow := false
+		for i := 0; i < n; i++ {
+			if strings.Contains(<-recorder.Events, reasonQueueOverflow) {
+				sawOverflow = true
+			}
+		}
+		if !sawOverflow {
+			t.Errorf("expected a %s warning event", reasonQueueOverflow)
+		}
+	})
+
+	t.Run("FIFO ordering is preserved across a restart", func(t *testing.T) {
+		cj := cronJob()
+		cj.Spec.ConcurrencyPolicy = queueConcurrent
+		cj.Spec.Schedule = onTheHour
+		now := justBeforeTheHour()
+		head := now.Add(-2 * time.Hour)
+		tail := now.Add(-1 * time.Hour)
+		// LastScheduleTime equal to now means no newly-missed schedule times this sync;
+		// PendingSchedules is seeded as though the controller restarted with these two
+		// entries already persisted in status.
+		cj.Status.LastScheduleTime = &metav1.Time{Time: now}
+		cj.Status.PendingSchedules = []metav1.Time{{Time: head}, {Time: tail}}
+
+		jc := &fakeJobControl{}
+		cjc := &fakeCJControl{CronJob: cj.DeepCopy()}
+		jm := ControllerV2{
+			jobControl:     jc,
+			cronJobControl: cjc,
+			recorder:       record.NewFakeRecorder(10),
+			now:            func() time.Time { return now },
+		}
+
+		cjCopy, _, _, err := jm.syncCronJob(context.TODO(), &cj, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(jc.Jobs) != 1 {
+			t.Fatalf("expected exactly one job created, got %d", len(jc.Jobs))
+		}
+		if want := getJobName(&cj, head); jc.Jobs[0].Name != want {
+			t.Errorf("expected the oldest pending entry to run first (FIFO), got job %q, want %q", jc.Jobs[0].Name, want)
+		}
+		if len(cjCopy.Status.PendingSchedules) != 1 || !cjCopy.Status.PendingSchedules[0].Time.Equal(tail) {
+			t.Errorf("expected only the newer pending entry to remain queued, got %v", cjCopy.Status.PendingSchedules)
+		}
+	})
+
+	t.Run("entries older than the deadline are pruned with a MissedSchedule warning", func(t *testing.T) {
+		cj := cronJob()
+		cj.Spec.ConcurrencyPolicy = queueConcurrent
+		cj.Spec.Schedule = onTheHour
+		deadline := int64(60)
+		cj.Spec.StartingDeadlineSeconds = &deadline
+		now := justBeforeTheHour()
+		stale := now.Add(-1 * time.Hour)
+		fresh := now.Add(-10 * time.Second)
+		cj.Status.LastScheduleTime = &metav1.Time{Time: now}
+		cj.Status.PendingSchedules = []metav1.Time{{Time: stale}, {Time: fresh}}
+
+		jc := &fakeJobControl{}
+		cjc := &fakeCJControl{CronJob: cj.DeepCopy()}
+		recorder := record.NewFakeRecorder(10)
+		jm := ControllerV2{
+			jobControl:     jc,
+			cronJobControl: cjc,
+			recorder:       recorder,
+			now:            func() time.Time { return now },
+		}
+
+		cjCopy, _, updateStatus, err := jm.syncCronJob(context.TODO(), &cj, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !updateStatus {
+			t.Errorf("expected updateStatus")
+		}
+		if len(jc.Jobs) != 1 {
+			t.Fatalf("expected the surviving (fresh) entry to run, got %d jobs", len(jc.Jobs))
+		}
+		if want := getJobName(&cj, fresh); jc.Jobs[0].Name != want {
+			t.Errorf("expected the fresh entry to be the one run, got job %q, want %q", jc.Jobs[0].Name, want)
+		}
+		if len(cjCopy.Status.PendingSchedules) != 0 {
+			t.Errorf("expected the queue to be empty after popping its only surviving entry, got %v", cjCopy.Status.PendingSchedules)
+		}
+
+		n := len(recorder.Events)
+		sawMissedSchedule := false
+		for i := 0; i < n; i++ {
+			if strings.Contains(<-recorder.Events, reasonMissSchedule) {
+				sawMissedSchedule = true
+			}
+		}
+		if !sawMissedSchedule {
+			t.Errorf("expected a %s warning event for the pruned entry", reasonMissSchedule)
+		}
+	})
+}
+
 type fakeQueue struct {
 	workqueue.RateLimitingInterface
 	delay time.Duration
@@ -1151,3 +1771,224 @@ func TestControllerV2GetJobsToBeReconciled(t *testing.T) {
 		})
 	}
 }
+
+// TestControllerV2AddFinalizer verifies that a CronJob without cronJobCleanupFinalizer
+// gets it added exactly once, and that a CronJob which already carries it is left alone.
+func TestControllerV2AddFinalizer(t *testing.T) {
+	cj := cronJob()
+	cjc := &fakeCJControl{CronJob: cj.DeepCopy()}
+	jm := ControllerV2{cronJobControl: cjc}
+
+	updated, err := jm.addFinalizer(&cj)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !hasFinalizer(updated, cronJobCleanupFinalizer) {
+		t.Fatalf("expected %q to be added, got finalizers %v", cronJobCleanupFinalizer, updated.Finalizers)
+	}
+	if len(cjc.Updates) != 1 {
+		t.Fatalf("expected exactly one Update call, got %d", len(cjc.Updates))
+	}
+
+	// Calling it again on a CronJob that already has the finalizer must be a no-op.
+	if _, err := jm.addFinalizer(updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cjc.Updates) != 1 {
+		t.Fatalf("expected addFinalizer to be a no-op once the finalizer is present, got %d Update calls", len(cjc.Updates))
+	}
+}
+
+// TestControllerV2SyncCronJobDeletion exercises the DeletionTimestamp branch of
+// syncCronJob under each CleanupPolicy, mirroring the "still active" table tests above.
+func TestControllerV2SyncCronJobDeletion(t *testing.T) {
+	testCases := map[string]struct {
+		cleanupPolicy   batchv1.CronJobCleanupPolicy
+		hasFinalizer    bool
+		active          bool
+		expectDelete    bool
+		expectFinalizer bool
+		expectRequeue   bool
+	}{
+		"no finalizer: left for garbage collection": {
+			hasFinalizer:    false,
+			active:          true,
+			expectFinalizer: false,
+		},
+		"delete policy, still active: deletes child and requeues": {
+			cleanupPolicy:   batchv1.CronJobCleanupPolicyDelete,
+			hasFinalizer:    true,
+			active:          true,
+			expectDelete:    true,
+			expectFinalizer: true,
+			expectRequeue:   true,
+		},
+		"delete policy, no active children: removes finalizer immediately": {
+			cleanupPolicy:   batchv1.CronJobCleanupPolicyDelete,
+			hasFinalizer:    true,
+			active:          false,
+			expectFinalizer: false,
+		},
+		"wait policy, still active: leaves child alone and requeues": {
+			cleanupPolicy:   batchv1.CronJobCleanupPolicyWait,
+			hasFinalizer:    true,
+			active:          true,
+			expectDelete:    false,
+			expectFinalizer: true,
+			expectRequeue:   true,
+		},
+		"wait policy, no active children: removes finalizer": {
+			cleanupPolicy:   batchv1.CronJobCleanupPolicyWait,
+			hasFinalizer:    true,
+			active:          false,
+			expectFinalizer: false,
+		},
+		"orphan policy, still active: removes finalizer without touching children": {
+			cleanupPolicy:   batchv1.CronJobCleanupPolicyOrphan,
+			hasFinalizer:    true,
+			active:          true,
+			expectDelete:    false,
+			expectFinalizer: false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			now := justBeforeTheHour()
+			cj := cronJob()
+			cj.Spec.Schedule = onTheHour
+			cj.Spec.CleanupPolicy = tc.cleanupPolicy
+			cj.DeletionTimestamp = &metav1.Time{Time: now}
+			if tc.hasFinalizer {
+				cj.Finalizers = []string{cronJobCleanupFinalizer}
+			}
+
+			var job *batchv1.Job
+			js := []*batchv1.Job{}
+			if tc.active {
+				var err error
+				job, err = getJobFromTemplate2(&cj, now)
+				if err != nil {
+					t.Fatalf("unexpected error creating a job from template: %v", err)
+				}
+				job.UID = "1234"
+				job.Namespace = cj.Namespace
+				ref, err := getRef(job)
+				if err != nil {
+					t.Fatalf("unexpected error getting the job object reference: %v", err)
+				}
+				cj.Status.Active = []v1.ObjectReference{*ref}
+				js = append(js, job)
+			}
+
+			jc := &fakeJobControl{Job: job}
+			cjc := &fakeCJControl{CronJob: cj.DeepCopy()}
+			jm := ControllerV2{
+				jobControl:     jc,
+				cronJobControl: cjc,
+				recorder:       record.NewFakeRecorder(10),
+				now:            func() time.Time { return now },
+			}
+
+			cjCopy, requeueAfter, _, err := jm.syncCronJob(context.TODO(), &cj, js)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if gotDelete := len(jc.DeleteJobName) > 0; gotDelete != tc.expectDelete {
+				t.Errorf("expected delete=%t, got DeleteJobName=%v", tc.expectDelete, jc.DeleteJobName)
+			}
+			if gotFinalizer := hasFinalizer(cjCopy, cronJobCleanupFinalizer); gotFinalizer != tc.expectFinalizer {
+				t.Errorf("expected finalizer present=%t, got finalizers %v", tc.expectFinalizer, cjCopy.Finalizers)
+			}
+			if gotRequeue := requeueAfter != nil; gotRequeue != tc.expectRequeue {
+				t.Errorf("expected requeueAfter set=%t, got %v", tc.expectRequeue, requeueAfter)
+			}
+		})
+	}
+}
+
+// TestControllerV2CleanupFinishedJobs exercises cleanupFinishedJobs/removeOldestJobs
+// directly, mirroring the "still active" table tests above: each case builds a fixed set
+// of already-finished Jobs and checks which ones get deleted once a history limit is set.
+func TestControllerV2CleanupFinishedJobs(t *testing.T) {
+	makeFinishedJob := func(name string, scheduledAt time.Time, condition batchv1.JobConditionType) *batchv1.Job {
+		job, err := getJobFromTemplate2(&batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Name: "mycronjob", Namespace: "snazzycats"}}, scheduledAt)
+		if err != nil {
+			t.Fatalf("unexpected error creating a job from template: %v", err)
+		}
+		job.Name = name
+		job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+			Type:   condition,
+			Status: v1.ConditionTrue,
+		})
+		return job
+	}
+
+	hour := justBeforeTheHour()
+	oldest := makeFinishedJob("oldest", hour, batchv1.JobComplete)
+	middle := makeFinishedJob("middle", hour.Add(time.Minute), batchv1.JobComplete)
+	newest := makeFinishedJob("newest", hour.Add(2*time.Minute), batchv1.JobComplete)
+	oldestFailed := makeFinishedJob("oldest-failed", hour, batchv1.JobFailed)
+	newestFailed := makeFinishedJob("newest-failed", hour.Add(time.Minute), batchv1.JobFailed)
+
+	testCases := map[string]struct {
+		successfulLimit *int32
+		failedLimit     *int32
+		jobs            []*batchv1.Job
+		expectDeleted   []string
+		expectUpdate    bool
+	}{
+		"no limits set: nothing deleted": {
+			jobs:          []*batchv1.Job{oldest, middle, newest},
+			expectDeleted: nil,
+		},
+		"successful count within limit: nothing deleted": {
+			successfulLimit: int32Ptr(3),
+			jobs:            []*batchv1.Job{oldest, middle, newest},
+			expectDeleted:   nil,
+		},
+		"successful count exceeds limit: oldest deleted": {
+			successfulLimit: int32Ptr(2),
+			jobs:            []*batchv1.Job{oldest, middle, newest},
+			expectDeleted:   []string{"oldest"},
+			expectUpdate:    true,
+		},
+		"failed count exceeds limit: oldest failed deleted": {
+			failedLimit:   int32Ptr(1),
+			jobs:          []*batchv1.Job{oldestFailed, newestFailed},
+			expectDeleted: []string{"oldest-failed"},
+			expectUpdate:  true,
+		},
+		"both limits exceeded: oldest of each deleted": {
+			successfulLimit: int32Ptr(2),
+			failedLimit:     int32Ptr(1),
+			jobs:            []*batchv1.Job{oldest, middle, newest, oldestFailed, newestFailed},
+			expectDeleted:   []string{"oldest", "oldest-failed"},
+			expectUpdate:    true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cj := cronJob()
+			cj.Spec.SuccessfulJobsHistoryLimit = tc.successfulLimit
+			cj.Spec.FailedJobsHistoryLimit = tc.failedLimit
+
+			jc := &fakeJobControl{}
+			jm := ControllerV2{
+				jobControl: jc,
+				recorder:   record.NewFakeRecorder(10),
+			}
+
+			gotUpdate := jm.cleanupFinishedJobs(context.TODO(), &cj, tc.jobs)
+			if gotUpdate != tc.expectUpdate {
+				t.Errorf("expected updateStatus=%t, got %t", tc.expectUpdate, gotUpdate)
+			}
+			if !reflect.DeepEqual(jc.DeleteJobName, tc.expectDeleted) {
+				t.Errorf("expected deleted jobs %v, got %v", tc.expectDeleted, jc.DeleteJobName)
+			}
+		})
+	}
+}
+
+func int32Ptr(i int32) *int32 { return &i }
@@ -0,0 +1,127 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// cronJobCleanupFinalizer blocks a CronJob's removal from etcd until this controller has
+// observed DeletionTimestamp and reconciled Status.Active down to empty according to
+// spec.cleanupPolicy. Without it, deleting a CronJob relies entirely on owner-reference
+// garbage collection, which fires independently of (and can race) this controller's own
+// bookkeeping of which Jobs it still considers active.
+const cronJobCleanupFinalizer = "batch.kubernetes.io/cronjob-cleanup"
+
+// cleanupRequeueDelay is how soon to recheck a CronJob that is being deleted but still has
+// active children to wait for or delete. It is much shorter than a typical schedule
+// interval because the condition being waited on (a Job disappearing from the lister) can
+// resolve within seconds of the apiserver processing the delete.
+const cleanupRequeueDelay = 5 * time.Second
+
+// hasFinalizer reports whether cj carries the named finalizer.
+func hasFinalizer(cj *batchv1.CronJob, name string) bool {
+	for _, f := range cj.Finalizers {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// addFinalizer appends name to cj's finalizers, persists the change via cronJobControl,
+// and returns the CronJob cronJobControl handed back (which carries the new
+// ResourceVersion), or cj unchanged if the finalizer was already present.
+func (jm *ControllerV2) addFinalizer(cj *batchv1.CronJob) (*batchv1.CronJob, error) {
+	if hasFinalizer(cj, cronJobCleanupFinalizer) {
+		return cj, nil
+	}
+	cj = cj.DeepCopy()
+	cj.Finalizers = append(cj.Finalizers, cronJobCleanupFinalizer)
+	return jm.cronJobControl.Update(cj)
+}
+
+// removeFinalizer drops name from cj's finalizers and persists the change via
+// cronJobControl.
+func (jm *ControllerV2) removeFinalizer(cj *batchv1.CronJob, name string) (*batchv1.CronJob, error) {
+	kept := make([]string, 0, len(cj.Finalizers))
+	for _, f := range cj.Finalizers {
+		if f != name {
+			kept = append(kept, f)
+		}
+	}
+	cj = cj.DeepCopy()
+	cj.Finalizers = kept
+	return jm.cronJobControl.Update(cj)
+}
+
+// syncCronJobDeletion reconciles a CronJob that has DeletionTimestamp set, tearing down (or
+// not) the Jobs still listed in Status.Active according to spec.cleanupPolicy before
+// removing cronJobCleanupFinalizer so the object can actually be removed from etcd. It
+// returns the same four-tuple shape as syncCronJob: a possibly-updated CronJob, how long to
+// wait before rechecking, whether the caller still needs to persist status, and an error.
+func (jm *ControllerV2) syncCronJobDeletion(cj *batchv1.CronJob, updateStatus bool) (*batchv1.CronJob, *time.Duration, bool, error) {
+	if !hasFinalizer(cj, cronJobCleanupFinalizer) {
+		// Pre-existing CronJobs from before this finalizer was introduced fall back to
+		// plain owner-reference garbage collection.
+		return cj, nil, updateStatus, nil
+	}
+
+	policy := cj.Spec.CleanupPolicy
+	if policy == "" {
+		policy = batchv1.CronJobCleanupPolicyDelete
+	}
+
+	if policy == batchv1.CronJobCleanupPolicyOrphan {
+		cj, err := jm.removeFinalizer(cj, cronJobCleanupFinalizer)
+		return cj, nil, updateStatus, err
+	}
+
+	if len(cj.Status.Active) == 0 {
+		cj, err := jm.removeFinalizer(cj, cronJobCleanupFinalizer)
+		return cj, nil, updateStatus, err
+	}
+
+	switch policy {
+	case batchv1.CronJobCleanupPolicyDelete:
+		for _, j := range cj.Status.Active {
+			job, err := jm.jobControl.GetJob(j.Namespace, j.Name)
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return cj, nil, updateStatus, err
+			}
+			deleteJob(cj, job, jm.jobControl, jm.recorder)
+			updateStatus = true
+		}
+	case batchv1.CronJobCleanupPolicyWait:
+		// Leave Status.Active alone: the children are left to finish on their own, and
+		// the loop in syncCronJob that drops finished Jobs from Status.Active as this
+		// CronJob is resynced is what eventually brings len(cj.Status.Active) to zero.
+	}
+
+	// Whether we just asked for deletions (policy Delete) or are only waiting for them to
+	// finish on their own (policy Wait), Status.Active won't reflect reality again until
+	// the next sync observes the Jobs missing from the lister, so requeue shortly rather
+	// than spinning the current sync.
+	requeueAfter := cleanupRequeueDelay
+	return cj, &requeueAfter, updateStatus, nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"context"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// jobControlInterface is an interface that knows how to add or delete jobs
+// created as an interface to allow testing.
+type jobControlInterface interface {
+	// GetJob retrieves a Job.
+	GetJob(namespace, name string) (*batchv1.Job, error)
+	// CreateJob creates new Jobs according to the spec.
+	CreateJob(namespace string, job *batchv1.Job) (*batchv1.Job, error)
+	// DeleteJob deletes the Job identified by name.
+	// TODO: delete by UID?
+	DeleteJob(namespace string, name string) error
+}
+
+// realJobControl is the default implementation of jobControlInterface.
+type realJobControl struct {
+	KubeClient kubernetes.Interface
+}
+
+var _ jobControlInterface = &realJobControl{}
+
+func (r realJobControl) GetJob(namespace, name string) (*batchv1.Job, error) {
+	return r.KubeClient.BatchV1().Jobs(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+func (r realJobControl) CreateJob(namespace string, job *batchv1.Job) (*batchv1.Job, error) {
+	return r.KubeClient.BatchV1().Jobs(namespace).Create(context.TODO(), job, metav1.CreateOptions{})
+}
+
+func (r realJobControl) DeleteJob(namespace string, name string) error {
+	background := metav1.DeletePropagationBackground
+	return r.KubeClient.BatchV1().Jobs(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{PropagationPolicy: &background})
+}
+
+// cronJobControlInterface is an interface that knows how to update CronJob status
+// created as an interface to allow testing.
+type cronJobControlInterface interface {
+	UpdateStatus(cj *batchv1.CronJob) (*batchv1.CronJob, error)
+	// Update persists changes to the CronJob's ObjectMeta/Spec, e.g. adding or removing
+	// the cleanup finalizer, as distinct from UpdateStatus's Status-only subresource.
+	Update(cj *batchv1.CronJob) (*batchv1.CronJob, error)
+}
+
+// realCronJobControl is the default implementation of cronJobControlInterface.
+type realCronJobControl struct {
+	KubeClient kubernetes.Interface
+}
+
+var _ cronJobControlInterface = &realCronJobControl{}
+
+func (c *realCronJobControl) UpdateStatus(cj *batchv1.CronJob) (*batchv1.CronJob, error) {
+	return c.KubeClient.BatchV1().CronJobs(cj.Namespace).UpdateStatus(context.TODO(), cj, metav1.UpdateOptions{})
+}
+
+func (c *realCronJobControl) Update(cj *batchv1.CronJob) (*batchv1.CronJob, error) {
+	return c.KubeClient.BatchV1().CronJobs(cj.Namespace).Update(context.TODO(), cj, metav1.UpdateOptions{})
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+// jobSchedulePhase records where a scheduling attempt is in its lifecycle, so that a
+// controller restart between "a Job create was attempted" and "the Job's ObjectMeta was
+// persisted to Status.Active" doesn't result in a duplicate Job for the same scheduled
+// time.
+type jobSchedulePhase string
+
+const (
+	jobSchedulePhaseStarted  jobSchedulePhase = "Started"
+	jobSchedulePhaseFinished jobSchedulePhase = "Finished"
+
+	// jobStateConfigMapSuffix names the per-CronJob ConfigMap that durably records the
+	// most recent scheduling attempt, ahead of the Job actually being created.
+	jobStateConfigMapSuffix = "-cronjob-state"
+
+	jobStateDataKey = "state"
+)
+
+// jobState is the persisted record of the most recent scheduling attempt for a CronJob.
+type jobState struct {
+	// ScheduledTime is the nominal time this attempt was scheduled for.
+	ScheduledTime time.Time `json:"scheduledTime"`
+	// Phase is Started as soon as the controller commits to creating a Job for
+	// ScheduledTime, and Finished once that Job has completed or failed.
+	Phase jobSchedulePhase `json:"phase"`
+	// FinishTime is set once Phase is Finished.
+	FinishTime *time.Time `json:"finishTime,omitempty"`
+	// Succeeded is set once Phase is Finished.
+	Succeeded bool `json:"succeeded,omitempty"`
+}
+
+// jobStateControlInterface knows how to durably read and write the most recent
+// scheduling attempt for a CronJob, so a crashed controller can tell "Started but never
+// Finished" apart from "never attempted" on restart.
+type jobStateControlInterface interface {
+	Get(cj *batchv1.CronJob) (*jobState, error)
+	Save(cj *batchv1.CronJob, state jobState) error
+}
+
+// configMapJobStateControl persists jobState into a ConfigMap named after the CronJob,
+// one per CronJob, in the CronJob's own namespace.
+type configMapJobStateControl struct {
+	KubeClient clientset.Interface
+}
+
+var _ jobStateControlInterface = &configMapJobStateControl{}
+
+func jobStateConfigMapName(cj *batchv1.CronJob) string {
+	return cj.Name + jobStateConfigMapSuffix
+}
+
+func (c *configMapJobStateControl) Get(cj *batchv1.CronJob) (*jobState, error) {
+	cm, err := c.KubeClient.CoreV1().ConfigMaps(cj.Namespace).Get(context.TODO(), jobStateConfigMapName(cj), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := cm.Data[jobStateDataKey]
+	if !ok {
+		return nil, nil
+	}
+	state := &jobState{}
+	if err := json.Unmarshal([]byte(raw), state); err != nil {
+		return nil, fmt.Errorf("corrupt job state for cronjob %s: %w", cj.Name, err)
+	}
+	return state, nil
+}
+
+func (c *configMapJobStateControl) Save(cj *batchv1.CronJob, state jobState) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	configMaps := c.KubeClient.CoreV1().ConfigMaps(cj.Namespace)
+	existing, err := configMaps.Get(context.TODO(), jobStateConfigMapName(cj), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		cm := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      jobStateConfigMapName(cj),
+				Namespace: cj.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(cj, controllerKind),
+				},
+			},
+			Data: map[string]string{jobStateDataKey: string(raw)},
+		}
+		_, err = configMaps.Create(context.TODO(), cm, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	// Update against the ConfigMap we just Got, so its ResourceVersion carries over:
+	// an Update built from scratch, with no ResourceVersion set, is rejected by the
+	// apiserver on every call after the first Create.
+	updated := existing.DeepCopy()
+	if updated.Data == nil {
+		updated.Data = make(map[string]string, 1)
+	}
+	updated.Data[jobStateDataKey] = string(raw)
+	_, err = configMaps.Update(context.TODO(), updated, metav1.UpdateOptions{})
+	return err
+}
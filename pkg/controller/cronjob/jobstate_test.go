@@ -0,0 +1,59 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestConfigMapJobStateControlSaveTwice guards against Save building an Update request
+// from scratch with no ResourceVersion: the apiserver (and the fake clientset, which
+// enforces this the same way) rejects that for every ConfigMap already on record, so a
+// second Save for the same CronJob must Get first and carry the ResourceVersion forward.
+func TestConfigMapJobStateControlSaveTwice(t *testing.T) {
+	cj := &batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Name: "my-cronjob", Namespace: "my-ns"}}
+	control := &configMapJobStateControl{KubeClient: fake.NewSimpleClientset()}
+
+	first := jobState{ScheduledTime: time.Unix(1, 0), Phase: jobSchedulePhaseStarted}
+	if err := control.Save(cj, first); err != nil {
+		t.Fatalf("first Save: unexpected error %v", err)
+	}
+	got, err := control.Get(cj)
+	if err != nil {
+		t.Fatalf("Get after first Save: unexpected error %v", err)
+	}
+	if got == nil || !got.ScheduledTime.Equal(first.ScheduledTime) || got.Phase != first.Phase {
+		t.Fatalf("Get after first Save: got %#v, want %#v", got, first)
+	}
+
+	second := jobState{ScheduledTime: time.Unix(1, 0), Phase: jobSchedulePhaseFinished, Succeeded: true}
+	if err := control.Save(cj, second); err != nil {
+		t.Fatalf("second Save: unexpected error %v", err)
+	}
+	got, err = control.Get(cj)
+	if err != nil {
+		t.Fatalf("Get after second Save: unexpected error %v", err)
+	}
+	if got == nil || got.Phase != second.Phase || got.Succeeded != second.Succeeded {
+		t.Fatalf("Get after second Save: got %#v, want %#v", got, second)
+	}
+}
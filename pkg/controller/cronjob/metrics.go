@@ -0,0 +1,180 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	// defaultBucketStart, defaultBucketFactor and defaultBucketCount describe the
+	// default exponential buckets for scheduleDelaySeconds: 1s, 2s, 4s, ... up
+	// to defaultBucketCount buckets.
+	defaultBucketStart  = 1.0
+	defaultBucketFactor = 2.0
+	defaultBucketCount  = 15
+
+	// defaultMetricsResetInterval drops accumulated per-CronJob label cardinality
+	// (e.g. for CronJobs that have since been deleted) on this cadence.
+	defaultMetricsResetInterval = 12 * time.Hour
+)
+
+// MetricsConfig exposes the knobs for the overdue-schedule metrics this controller
+// publishes: the exponential histogram buckets for schedule delay, and how often the
+// per-CronJob label cardinality is dropped and rebuilt from scratch.
+type MetricsConfig struct {
+	// BucketStart, BucketFactor and BucketCount parameterize the exponential buckets
+	// used for the schedule delay histogram.
+	BucketStart  float64
+	BucketFactor float64
+	BucketCount  int
+
+	// ResetInterval is how often the metrics below are reset, to bound the label
+	// cardinality accumulated from CronJobs that have since been deleted.
+	ResetInterval time.Duration
+}
+
+// DefaultMetricsConfig returns the MetricsConfig used when NewControllerV2 is not given
+// an explicit one.
+func DefaultMetricsConfig() MetricsConfig {
+	return MetricsConfig{
+		BucketStart:   defaultBucketStart,
+		BucketFactor:  defaultBucketFactor,
+		BucketCount:   defaultBucketCount,
+		ResetInterval: defaultMetricsResetInterval,
+	}
+}
+
+// cronJobMetrics holds the metrics this controller publishes about scheduling health,
+// built from a MetricsConfig.
+type cronJobMetrics struct {
+	// oldestMissedScheduleAgeSeconds is the age, in seconds, of the oldest missed
+	// scheduled time that has not yet produced a Job, per CronJob. It is cleared once
+	// the CronJob has no missed schedules outstanding.
+	oldestMissedScheduleAgeSeconds *metrics.GaugeVec
+	// scheduleDelaySeconds is the time between a Job's scheduled time and when the
+	// Job was actually created for it.
+	scheduleDelaySeconds *metrics.HistogramVec
+
+	resetInterval time.Duration
+
+	registerOnce sync.Once
+}
+
+// sharedCronJobMetrics and sharedCronJobMetricsOnce make newCronJobMetrics below
+// process-wide rather than per-controller: legacyregistry rejects a second registration
+// of the same metric name, so every ControllerV2 built in this process, including ones
+// constructed back-to-back by tests that loop NewControllerV2 across table cases, must
+// observe to one shared set of vectors instead of each allocating and registering its own.
+var (
+	sharedCronJobMetrics     *cronJobMetrics
+	sharedCronJobMetricsOnce sync.Once
+)
+
+// newCronJobMetrics returns the process-wide cronJobMetrics, building it from cfg the
+// first time it is called. Only the first caller's MetricsConfig takes effect; later
+// calls, from a later NewControllerV2 in the same process, get back the same instance
+// regardless of the cfg they pass.
+func newCronJobMetrics(cfg MetricsConfig) *cronJobMetrics {
+	sharedCronJobMetricsOnce.Do(func() {
+		sharedCronJobMetrics = &cronJobMetrics{
+			oldestMissedScheduleAgeSeconds: metrics.NewGaugeVec(
+				&metrics.GaugeOpts{
+					Subsystem:      "cronjob_controller",
+					Name:           "oldest_missed_schedule_age_seconds",
+					Help:           "Age of the oldest missed scheduled time that has not yet produced a Job, per CronJob.",
+					StabilityLevel: metrics.ALPHA,
+				},
+				[]string{"namespace", "name"},
+			),
+			scheduleDelaySeconds: metrics.NewHistogramVec(
+				&metrics.HistogramOpts{
+					Subsystem:      "cronjob_controller",
+					Name:           "schedule_delay_seconds",
+					Help:           "Delay between a Job's scheduled time and when it was actually created, per CronJob.",
+					Buckets:        metrics.ExponentialBuckets(cfg.BucketStart, cfg.BucketFactor, cfg.BucketCount),
+					StabilityLevel: metrics.ALPHA,
+				},
+				[]string{"namespace", "name"},
+			),
+			resetInterval: cfg.ResetInterval,
+		}
+	})
+	return sharedCronJobMetrics
+}
+
+// register publishes m's vectors to the legacy registry the first time it is called.
+// Because m is itself a process-wide singleton returned by newCronJobMetrics, and every
+// ControllerV2 calls register() on construction, registerOnce is what keeps the second
+// and later ControllerV2 built in a process from re-registering the same collectors and
+// panicking with a duplicate-collector error.
+func (m *cronJobMetrics) register() {
+	m.registerOnce.Do(func() {
+		legacyregistry.MustRegister(m.oldestMissedScheduleAgeSeconds)
+		legacyregistry.MustRegister(m.scheduleDelaySeconds)
+	})
+}
+
+// runResetLoop periodically resets both vectors, dropping label cardinality
+// accumulated for CronJobs that no longer exist. It blocks until stopCh is closed.
+func (m *cronJobMetrics) runResetLoop(stopCh <-chan struct{}) {
+	if m == nil || m.resetInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(m.resetInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.oldestMissedScheduleAgeSeconds.Reset()
+			m.scheduleDelaySeconds.Reset()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// observeOverdue records the age of a still-unmet scheduled time for cj.
+func (m *cronJobMetrics) observeOverdue(cj *batchv1.CronJob, age time.Duration) {
+	if m == nil {
+		return
+	}
+	m.oldestMissedScheduleAgeSeconds.WithLabelValues(cj.Namespace, cj.Name).Set(age.Seconds())
+}
+
+// clearOverdue drops the overdue-age gauge for cj, e.g. once a Job has been created for
+// its most recent missed scheduled time.
+func (m *cronJobMetrics) clearOverdue(cj *batchv1.CronJob) {
+	if m == nil {
+		return
+	}
+	m.oldestMissedScheduleAgeSeconds.DeleteLabelValues(cj.Namespace, cj.Name)
+}
+
+// observeScheduleDelay records how long after scheduledTime the Job for it was
+// actually created.
+func (m *cronJobMetrics) observeScheduleDelay(cj *batchv1.CronJob, delay time.Duration) {
+	if m == nil {
+		return
+	}
+	m.scheduleDelaySeconds.WithLabelValues(cj.Namespace, cj.Name).Observe(delay.Seconds())
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"k8s.io/klog/v2"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// queueConcurrent is the value of spec.concurrencyPolicy that buffers schedule times
+	// blocked by a still-active Job, or missed outright, into Status.PendingSchedules for
+	// sequential replay once the active Job finishes, rather than running them
+	// concurrently (Allow), dropping them (Forbid), or killing the active Job (Replace).
+	//
+	// It is declared here rather than as a batchv1.ConcurrencyPolicy constant alongside
+	// ForbidConcurrent/ReplaceConcurrent because those live in k8s.io/api/batch/v1,
+	// outside this checkout.
+	queueConcurrent batchv1.ConcurrencyPolicy = "Queue"
+
+	// defaultMaxQueuedRuns bounds Status.PendingSchedules when spec.maxQueuedRuns is unset.
+	defaultMaxQueuedRuns = 10
+
+	// reasonQueueOverflow is the event reason recorded when pending schedule times are
+	// dropped because spec.maxQueuedRuns was exceeded.
+	reasonQueueOverflow = "QueueOverflow"
+)
+
+// maxQueuedRuns returns the effective cap on cj.Status.PendingSchedules.
+func maxQueuedRuns(cj *batchv1.CronJob) int32 {
+	if cj.Spec.MaxQueuedRuns != nil {
+		return *cj.Spec.MaxQueuedRuns
+	}
+	return defaultMaxQueuedRuns
+}
+
+// syncQueueConcurrency implements spec.concurrencyPolicy: Queue. It admits every schedule
+// time missed since Status.LastScheduleTime into Status.PendingSchedules (pruning entries
+// older than spec.startingDeadlineSeconds and capping the queue at spec.maxQueuedRuns,
+// both with a warning event), then, if no Job is currently active, pops and runs the
+// oldest pending entry.
+func (jm *ControllerV2) syncQueueConcurrency(cj *batchv1.CronJob, sched cron.Schedule, now time.Time) (*batchv1.CronJob, *time.Duration, bool, error) {
+	updateStatus := false
+
+	starts, err := getRecentUnmetScheduleTimes(*cj, now)
+	if err != nil {
+		// this is likely a user error in defining the spec, and we should not
+		// automatically retry.
+		recordScheduleError(jm.recorder, cj, err)
+		return cj, nil, updateStatus, nil
+	}
+
+	pending := make([]time.Time, 0, len(cj.Status.PendingSchedules)+len(starts))
+	for _, t := range cj.Status.PendingSchedules {
+		pending = append(pending, t.Time)
+	}
+	pending = append(pending, starts...)
+	if len(starts) > 0 {
+		cj.Status.LastScheduleTime = &metav1.Time{Time: starts[len(starts)-1]}
+		updateStatus = true
+	}
+
+	if cj.Spec.StartingDeadlineSeconds != nil {
+		cutoff := now.Add(-time.Second * time.Duration(*cj.Spec.StartingDeadlineSeconds))
+		kept := pending[:0]
+		pruned := 0
+		for _, t := range pending {
+			if t.Before(cutoff) {
+				pruned++
+				continue
+			}
+			kept = append(kept, t)
+		}
+		pending = kept
+		if pruned > 0 {
+			jm.recorder.Eventf(cj, v1.EventTypeWarning, reasonMissSchedule, "Missed %d queued start time(s) older than spec.startingDeadlineSeconds", pruned)
+			updateStatus = true
+		}
+	}
+
+	if max := maxQueuedRuns(cj); int32(len(pending)) > max {
+		dropped := len(pending) - int(max)
+		jm.recorder.Eventf(cj, v1.EventTypeWarning, reasonQueueOverflow, "Dropped %d queued schedule(s) exceeding spec.maxQueuedRuns=%d", dropped, max)
+		pending = pending[dropped:]
+		updateStatus = true
+	}
+
+	cj.Status.PendingSchedules = toMetaTimes(pending)
+
+	if len(cj.Status.Active) == 0 && len(pending) > 0 {
+		head := pending[0]
+		cj.Status.PendingSchedules = toMetaTimes(pending[1:])
+		updateStatus = true
+
+		created, err := jm.createQueuedJob(cj, head, now)
+		if err != nil {
+			return cj, nil, true, err
+		}
+		if created {
+			// Don't wait for the next cron tick: either another pending entry is
+			// already due, or this sync should settle its status update promptly.
+			immediately := time.Duration(0)
+			return cj, &immediately, true, nil
+		}
+	}
+
+	return cj, nextRequeueForQueue(cj, sched, now), updateStatus, nil
+}
+
+// toMetaTimes converts ts to the metav1.Time slice Status.PendingSchedules is stored as.
+func toMetaTimes(ts []time.Time) []metav1.Time {
+	if len(ts) == 0 {
+		return nil
+	}
+	out := make([]metav1.Time, len(ts))
+	for i, t := range ts {
+		out[i] = metav1.Time{Time: t}
+	}
+	return out
+}
+
+// nextRequeueForQueue is like nextScheduledTimeDuration, but also accounts for the
+// deadline of the oldest pending entry, so a CronJob waiting on an active Job to finish
+// still wakes up in time to prune that entry once it falls outside
+// spec.startingDeadlineSeconds instead of only waking up on the next cron tick.
+func nextRequeueForQueue(cj *batchv1.CronJob, sched cron.Schedule, now time.Time) *time.Duration {
+	d := *nextScheduledTimeDuration(sched, now)
+	if len(cj.Status.PendingSchedules) > 0 && cj.Spec.StartingDeadlineSeconds != nil {
+		deadlineAt := cj.Status.PendingSchedules[0].Time.Add(time.Second * time.Duration(*cj.Spec.StartingDeadlineSeconds))
+		if untilDeadline := deadlineAt.Sub(now); untilDeadline < d {
+			if untilDeadline < 0 {
+				untilDeadline = 0
+			}
+			d = untilDeadline
+		}
+	}
+	return &d
+}
+
+// createQueuedJob creates a Job from cj's template for a scheduled time popped off
+// Status.PendingSchedules, deduping against jm.jobStateControl's record of an unresolved
+// in-flight attempt for the same scheduledTime exactly as the non-queued path does. It
+// reports whether a Job was newly created.
+func (jm *ControllerV2) createQueuedJob(cj *batchv1.CronJob, scheduledTime, now time.Time) (bool, error) {
+	if jm.jobStateControl != nil {
+		state, err := jm.jobStateControl.Get(cj)
+		if err != nil {
+			klog.V(2).InfoS("Unable to read job state, proceeding without crash recovery for this sync", "cronjob", klog.KObj(cj), "err", err)
+		} else if state != nil && state.Phase == jobSchedulePhaseStarted && state.ScheduledTime.Equal(scheduledTime) {
+			klog.V(4).InfoS("Scheduled time already has an in-flight or unresolved Job, not creating another", "cronjob", klog.KObj(cj), "scheduledTime", scheduledTime)
+			return false, nil
+		}
+		if err := jm.jobStateControl.Save(cj, jobState{ScheduledTime: scheduledTime, Phase: jobSchedulePhaseStarted}); err != nil {
+			klog.V(2).InfoS("Unable to persist in-flight job state before create", "cronjob", klog.KObj(cj), "err", err)
+		}
+	}
+
+	jobReq, err := getJobFromTemplate2(cj, scheduledTime)
+	if err != nil {
+		klog.ErrorS(err, "Unable to make Job from template", "cronjob", klog.KObj(cj))
+		return false, err
+	}
+	if jobReq.Annotations == nil {
+		jobReq.Annotations = make(map[string]string, 1)
+	}
+	jobReq.Annotations[jobOriginAnnotation] = jobOriginScheduled
+	jobResp, err := jm.jobControl.CreateJob(cj.Namespace, jobReq)
+	switch {
+	case apierrors.IsAlreadyExists(err):
+		// If the job is created by other actor (time drift, controller restart), we shouldn't fail.
+		klog.V(4).InfoS("Job already exists", "cronjob", klog.KObj(cj), "job", klog.KObj(jobReq))
+		return false, nil
+	case err != nil:
+		jm.recorder.Eventf(cj, v1.EventTypeWarning, reasonFailedCreate, "Error creating job: %v", err)
+		return false, err
+	}
+
+	klog.V(4).InfoS("Created Job", "job", klog.KObj(jobResp), "cronjob", klog.KObj(cj))
+	jm.recorder.Eventf(cj, v1.EventTypeNormal, reasonSuccessfulCreate, "Created job %v", jobResp.Name)
+	jm.metrics.clearOverdue(cj)
+	jm.metrics.observeScheduleDelay(cj, now.Sub(scheduledTime))
+
+	jobRef, err := getRef(jobResp)
+	if err != nil {
+		klog.V(2).InfoS("Unable to make object reference", "cronjob", klog.KObj(cj), "err", err)
+		return true, fmt.Errorf("unable to make object reference for job for %s", klog.KObj(cj))
+	}
+	alreadyActive := false
+	for _, j := range cj.Status.Active {
+		if j.Name == jobRef.Name && j.Namespace == jobRef.Namespace {
+			alreadyActive = true
+			break
+		}
+	}
+	if !alreadyActive {
+		cj.Status.Active = append(cj.Status.Active, *jobRef)
+	}
+	cj.Status.LastScheduleTime = &metav1.Time{Time: scheduledTime}
+	return true, nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+// timePlanSchedule adapts a batchv1.TimePlan (day-of-week/hour/minute bitmaps) to the
+// cron.Schedule interface, so the rest of the controller can treat it exactly like a
+// parsed cron expression.
+type timePlanSchedule struct {
+	plan *batchv1.TimePlan
+}
+
+var _ cron.Schedule = timePlanSchedule{}
+
+// Next returns the earliest instant strictly after t whose weekday, hour and minute are
+// all enabled in the plan.
+func (s timePlanSchedule) Next(t time.Time) time.Time {
+	// Start at the next whole minute, then walk forward at most 7 days (in minutes)
+	// looking for a match; the plan is guaranteed by validation to enable at least one
+	// day, hour and minute, so this always terminates.
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	for i := 0; i < 7*24*60; i++ {
+		if s.plan.Days[int(next.Weekday())] && s.plan.Hours[next.Hour()] && s.plan.Minutes[next.Minute()] {
+			return next
+		}
+		next = next.Add(time.Minute)
+	}
+	// Unreachable if the plan passed validateTimePlan.
+	return next
+}
+
+// validateTimePlan ensures at least one entry per category is enabled, mirroring the
+// validation that a free-form schedule string gets from cron.ParseStandard.
+func validateTimePlan(plan *batchv1.TimePlan) error {
+	if !anyTrue(plan.Days[:]) {
+		return fmt.Errorf("timePlan.days: at least one day of the week must be enabled")
+	}
+	if !anyTrue(plan.Hours[:]) {
+		return fmt.Errorf("timePlan.hours: at least one hour must be enabled")
+	}
+	if !anyTrue(plan.Minutes[:]) {
+		return fmt.Errorf("timePlan.minutes: at least one minute must be enabled")
+	}
+	return nil
+}
+
+func anyTrue(bs []bool) bool {
+	for _, b := range bs {
+		if b {
+			return true
+		}
+	}
+	return false
+}
+
+// getSchedule resolves the effective cron.Schedule for a CronJob, switching on whether
+// spec.timePlan or the free-form spec.schedule string is set, and wrapping the result so
+// that it is evaluated in spec.timeZone, or the schedule's own inline CRON_TZ=/TZ= prefix,
+// or UTC if neither is set.
+func getSchedule(cj *batchv1.CronJob) (cron.Schedule, error) {
+	loc, scheduleText, err := resolveTimeZoneAndSchedule(cj, cj.Spec.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	var sched cron.Schedule
+	if cj.Spec.TimePlan != nil {
+		if err := validateTimePlan(cj.Spec.TimePlan); err != nil {
+			return nil, err
+		}
+		sched = timePlanSchedule{plan: cj.Spec.TimePlan}
+	} else {
+		s, err := cron.ParseStandard(scheduleText)
+		if err != nil {
+			return nil, err
+		}
+		sched = s
+	}
+
+	return locatedSchedule{sched: sched, loc: loc}, nil
+}
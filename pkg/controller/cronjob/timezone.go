@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+// locatedSchedule wraps a cron.Schedule so that Next is evaluated against the wall-clock
+// fields (hour, minute, weekday, ...) of its location rather than of whatever location the
+// instant passed in happens to carry. This makes DST transitions behave the way a human
+// reading spec.timeZone would expect: a spring-forward gap is skipped forward to the next
+// local time that actually exists, and a fall-back repeat only fires once, because the
+// controller always advances from the last-scheduled UTC instant (see
+// getRecentUnmetScheduleTimes) rather than from a remembered local wall-clock string.
+type locatedSchedule struct {
+	sched cron.Schedule
+	loc   *time.Location
+}
+
+var _ cron.Schedule = locatedSchedule{}
+
+func (s locatedSchedule) Next(t time.Time) time.Time {
+	return s.sched.Next(t.In(s.loc))
+}
+
+// invalidTimeZoneError distinguishes a bad spec.timeZone/CRON_TZ zone name from a bad
+// cron expression, so the caller can record the more specific InvalidTimeZone event reason
+// for the former rather than lumping it in with InvalidSchedule.
+type invalidTimeZoneError struct {
+	err error
+}
+
+func (e *invalidTimeZoneError) Error() string { return e.err.Error() }
+func (e *invalidTimeZoneError) Unwrap() error { return e.err }
+
+// cronTZPrefix and legacyTZPrefix are the two spellings of the inline time zone prefix
+// that github.com/robfig/cron/v3 itself understands in a schedule string, e.g.
+// "CRON_TZ=America/New_York 0 9 * * *". We strip and resolve it ourselves, alongside
+// spec.timeZone, rather than letting cron.ParseStandard see it: that library defaults an
+// unprefixed schedule to time.Local, which would make a CronJob's behavior depend on the
+// host the controller happens to be running on.
+const (
+	cronTZPrefix   = "CRON_TZ="
+	legacyTZPrefix = "TZ="
+)
+
+// splitScheduleTimeZone extracts a leading CRON_TZ=/TZ= zone name from schedule, returning
+// it along with the remaining schedule text and whether either prefix was present at all.
+// hasPrefix lets the caller tell "no prefix" apart from "prefix present but the zone name
+// after it is empty or missing a separating space", both of which must be rejected rather
+// than silently falling back to UTC as if no prefix had been written.
+func splitScheduleTimeZone(schedule string) (zoneName, rest string, hasPrefix bool) {
+	prefix := ""
+	switch {
+	case strings.HasPrefix(schedule, cronTZPrefix):
+		prefix = cronTZPrefix
+	case strings.HasPrefix(schedule, legacyTZPrefix):
+		prefix = legacyTZPrefix
+	default:
+		return "", schedule, false
+	}
+	i := strings.IndexByte(schedule, ' ')
+	if i < 0 {
+		return "", schedule, true
+	}
+	return schedule[len(prefix):i], strings.TrimSpace(schedule[i:]), true
+}
+
+// resolveTimeZoneAndSchedule returns the effective *time.Location for cj and schedule with
+// any inline CRON_TZ=/TZ= prefix stripped off, so the cron parser never has to consider it.
+// spec.timeZone and the inline prefix are mutually exclusive: setting both is rejected so
+// there is never a question of which one actually governs. If neither is set, the zone is
+// time.UTC. An invalid IANA zone name, from either source, is returned as an error so the
+// caller can surface it the same way an unparseable schedule string is surfaced.
+func resolveTimeZoneAndSchedule(cj *batchv1.CronJob, schedule string) (*time.Location, string, error) {
+	zoneName, stripped, hasPrefix := splitScheduleTimeZone(schedule)
+
+	if cj.Spec.TimeZone != nil && *cj.Spec.TimeZone != "" {
+		if hasPrefix {
+			return nil, "", &invalidTimeZoneError{fmt.Errorf("spec.schedule must not also carry a %s prefix when spec.timeZone is set", cronTZPrefix)}
+		}
+		loc, err := time.LoadLocation(*cj.Spec.TimeZone)
+		if err != nil {
+			return nil, "", &invalidTimeZoneError{fmt.Errorf("unknown time zone %q: %w", *cj.Spec.TimeZone, err)}
+		}
+		return loc, stripped, nil
+	}
+
+	if !hasPrefix {
+		return time.UTC, stripped, nil
+	}
+	if zoneName == "" {
+		return nil, "", &invalidTimeZoneError{fmt.Errorf("spec.schedule carries a time zone prefix with no zone name")}
+	}
+	loc, err := time.LoadLocation(zoneName)
+	if err != nil {
+		return nil, "", &invalidTimeZoneError{fmt.Errorf("unknown time zone %q: %w", zoneName, err)}
+	}
+	return loc, stripped, nil
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cronjob contains the controller for CronJob objects: it watches
+// for changes to CronJob resources and creates/deletes the Jobs they
+// describe according to their schedules.
+package cronjob
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ref "k8s.io/client-go/tools/reference"
+)
+
+// controllerKind is the GroupVersionKind of the CronJob type, used to stamp
+// owner references onto the Jobs this controller creates.
+var controllerKind = batchv1.SchemeGroupVersion.WithKind("CronJob")
+
+// byScheduleTime sorts jobs by creation time, oldest first, using name as a tie breaker.
+type byScheduleTime []*batchv1.Job
+
+func (o byScheduleTime) Len() int      { return len(o) }
+func (o byScheduleTime) Swap(i, j int) { o[i], o[j] = o[j], o[i] }
+func (o byScheduleTime) Less(i, j int) bool {
+	ti, oki := getScheduledTimeForJob(o[i])
+	tj, okj := getScheduledTimeForJob(o[j])
+	if !oki && !okj {
+		return o[i].Name < o[j].Name
+	}
+	if !oki {
+		return false
+	}
+	if !okj {
+		return true
+	}
+	if ti.Equal(*tj) {
+		return o[i].Name < o[j].Name
+	}
+	return ti.Before(*tj)
+}
+
+// getJobFromTemplate2 makes a Job from a CronJob, naming it deterministically from the
+// scheduled time so that the same scheduled time never produces two distinct Jobs.
+func getJobFromTemplate2(cj *batchv1.CronJob, scheduledTime time.Time) (*batchv1.Job, error) {
+	labels := make(map[string]string, len(cj.Spec.JobTemplate.Labels))
+	for k, v := range cj.Spec.JobTemplate.Labels {
+		labels[k] = v
+	}
+	annotations := make(map[string]string, len(cj.Spec.JobTemplate.Annotations))
+	for k, v := range cj.Spec.JobTemplate.Annotations {
+		annotations[k] = v
+	}
+	name := getJobName(cj, scheduledTime)
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:            labels,
+			Annotations:       annotations,
+			Name:              name,
+			Namespace:         cj.Namespace,
+			CreationTimestamp: metav1.Time{Time: scheduledTime},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cj, controllerKind),
+			},
+		},
+	}
+	cj.Spec.JobTemplate.Spec.DeepCopyInto(&job.Spec)
+	return job, nil
+}
+
+// getJobName derives a deterministic Job name for a given nominal start time, so a
+// scheduled time can never produce two distinct Jobs even if the controller restarts.
+func getJobName(cj *batchv1.CronJob, scheduledTime time.Time) string {
+	return fmt.Sprintf("%s-%d", cj.Name, scheduledTime.Unix()/60)
+}
+
+// getParentUIDFromJob extracts the UID of a Job's owning CronJob, if any.
+func getParentUIDFromJob(j batchv1.Job) (types.UID, bool) {
+	controllerRef := metav1.GetControllerOf(&j)
+	if controllerRef == nil {
+		return types.UID(""), false
+	}
+	if controllerRef.Kind != controllerKind.Kind {
+		return types.UID(""), false
+	}
+	return controllerRef.UID, true
+}
+
+// groupJobsByParent groups jobs into a map keyed by the owning CronJob's UID. Jobs with
+// no resolvable parent are ignored.
+func groupJobsByParent(js []*batchv1.Job) map[types.UID][]*batchv1.Job {
+	jobsByCj := make(map[types.UID][]*batchv1.Job)
+	for _, job := range js {
+		parentUID, found := getParentUIDFromJob(*job)
+		if !found {
+			continue
+		}
+		jobsByCj[parentUID] = append(jobsByCj[parentUID], job)
+	}
+	return jobsByCj
+}
+
+// getRecentUnmetScheduleTimes finds the schedule times that have been missed since the
+// last schedule time, up to now. If there are too many (more than 100), an error is
+// returned so the caller can surface it as an event rather than spinning forever.
+func getRecentUnmetScheduleTimes(cj batchv1.CronJob, now time.Time) ([]time.Time, error) {
+	starts := []time.Time{}
+	sched, err := getSchedule(&cj)
+	if err != nil {
+		return starts, fmt.Errorf("unparseable schedule: %q: %w", cj.Spec.Schedule, err)
+	}
+
+	var earliestTime time.Time
+	if cj.Status.LastScheduleTime != nil {
+		earliestTime = cj.Status.LastScheduleTime.Time
+	} else {
+		// If none found, then this is either a recently created cronJob, or the
+		// active/completed info was somehow lost.
+		earliestTime = cj.ObjectMeta.CreationTimestamp.Time
+	}
+	if cj.Spec.StartingDeadlineSeconds != nil {
+		schedulingDeadline := now.Add(-time.Second * time.Duration(*cj.Spec.StartingDeadlineSeconds))
+		if schedulingDeadline.After(earliestTime) {
+			earliestTime = schedulingDeadline
+		}
+	}
+	if earliestTime.After(now) {
+		return []time.Time{}, nil
+	}
+
+	for t := sched.Next(earliestTime); !t.After(now); t = sched.Next(t) {
+		starts = append(starts, t)
+		// A CronJob might miss several starts, e.g. if the controller was down over a
+		// weekend. If there is a bug or clock skew, the number of missed starts could be
+		// huge, so bail out rather than eating all the CPU and memory of this controller.
+		if len(starts) > 100 {
+			return []time.Time{}, fmt.Errorf("too many missed start times (> 100). Set or decrease .spec.startingDeadlineSeconds or check clock skew")
+		}
+	}
+	return starts, nil
+}
+
+// getNextScheduleTime returns the most recent missed schedule time at or before now,
+// or nil if the CronJob is not due.
+func getNextScheduleTime(cj batchv1.CronJob, now time.Time) (*time.Time, error) {
+	starts, err := getRecentUnmetScheduleTimes(cj, now)
+	if err != nil || len(starts) == 0 {
+		return nil, err
+	}
+	return &starts[len(starts)-1], nil
+}
+
+// recordScheduleError emits the warning event for an unparseable schedule, using the more
+// specific InvalidTimeZone reason when err came from resolving spec.timeZone or an inline
+// CRON_TZ=/TZ= prefix rather than from the cron expression itself.
+func recordScheduleError(recorder record.EventRecorder, cj *batchv1.CronJob, err error) {
+	var tzErr *invalidTimeZoneError
+	if errors.As(err, &tzErr) {
+		recorder.Eventf(cj, v1.EventTypeWarning, reasonInvalidTimeZone, "Invalid time zone: %s", err)
+		return
+	}
+	recorder.Eventf(cj, v1.EventTypeWarning, reasonInvalidSchedule, "Unparseable schedule: %q : %s", cj.Spec.Schedule, err)
+}
+
+// isManagedByExternalController reports whether cj.Spec.ManagedBy names a controller
+// other than the reserved in-tree value, meaning this controller should not make
+// scheduling decisions (create/delete Jobs or advance scheduling status) for it.
+func isManagedByExternalController(cj *batchv1.CronJob) bool {
+	return cj.Spec.ManagedBy != "" && cj.Spec.ManagedBy != defaultManagedBy
+}
+
+func inActiveList(cj batchv1.CronJob, uid types.UID) bool {
+	for _, j := range cj.Status.Active {
+		if j.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+func deleteFromActiveList(cj *batchv1.CronJob, uid types.UID) {
+	if cj == nil {
+		return
+	}
+	newActive := []v1.ObjectReference{}
+	for _, j := range cj.Status.Active {
+		if j.UID != uid {
+			newActive = append(newActive, j)
+		}
+	}
+	cj.Status.Active = newActive
+}
+
+// getRef returns the ObjectReference for o.
+func getRef(o runtime.Object) (*v1.ObjectReference, error) {
+	return ref.GetReference(scheme.Scheme, o)
+}